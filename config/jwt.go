@@ -0,0 +1,68 @@
+package config
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// JWTKey describes one key of the JWT key set. HS256 keys carry their
+// secret inline; RS256 and EdDSA keys point at PEM files. Only the signing
+// key needs a private key, keys kept for verification during a rotation can
+// be given by their public key alone.
+type JWTKey struct {
+	ID             string `json:"kid"`
+	Algorithm      string `json:"alg"` // "HS256", "RS256" or "EdDSA"
+	Secret         string `json:"secret"`
+	PrivateKeyFile string `json:"private_key_file"`
+	PublicKeyFile  string `json:"public_key_file"`
+}
+
+// JWTKeySet lists every key tokens may be verified with and names the one
+// new tokens are signed with.
+type JWTKeySet struct {
+	SigningKey string   `json:"signing_key"`
+	Keys       []JWTKey `json:"keys"`
+}
+
+// JWTKeys reads the key set from the JSON file named by JWT_KEYS_FILE.
+// Without it a single HS256 key is built from JWT_SECRET (and JWT_KEY_ID).
+// Without either it fails, unless InsecureDevKeys allows a random key that
+// only lasts as long as the process.
+func JWTKeys() (JWTKeySet, error) {
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		var set JWTKeySet
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return set, fmt.Errorf("reading JWT key set: %w", err)
+		}
+		if err := json.Unmarshal(data, &set); err != nil {
+			return set, fmt.Errorf("parsing JWT key set %s: %w", path, err)
+		}
+		return set, nil
+	}
+
+	kid := os.Getenv("JWT_KEY_ID")
+	if kid == "" {
+		kid = "default"
+	}
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		if !InsecureDevKeys() {
+			return JWTKeySet{}, errors.New("neither JWT_KEYS_FILE nor JWT_SECRET is set, set INSECURE_DEV_KEYS=true to use a temporary key in development")
+		}
+		log.Println("Neither JWT_KEYS_FILE nor JWT_SECRET is set, using a temporary random key")
+		key, err := randomDevKey()
+		if err != nil {
+			return JWTKeySet{}, err
+		}
+		kid, secret = "development", hex.EncodeToString(key)
+	}
+	return JWTKeySet{
+		SigningKey: kid,
+		Keys:       []JWTKey{{ID: kid, Algorithm: "HS256", Secret: secret}},
+	}, nil
+}
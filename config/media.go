@@ -0,0 +1,22 @@
+package config
+
+import "os"
+
+// MediaDir returns the directory uploaded event media is stored in, read
+// from the MEDIA_DIR environment variable.
+func MediaDir() string {
+	if dir := os.Getenv("MEDIA_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads"
+}
+
+// MediaBaseURL returns the URL prefix media URLs are built with, read from
+// the MEDIA_BASE_URL environment variable. Set it when files are served from
+// a CDN instead of this server's /media route.
+func MediaBaseURL() string {
+	if url := os.Getenv("MEDIA_BASE_URL"); url != "" {
+		return url
+	}
+	return "/media"
+}
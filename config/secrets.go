@@ -0,0 +1,42 @@
+package config
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+)
+
+// InsecureDevKeys reports whether INSECURE_DEV_KEYS allows the server to
+// start without configured signing keys. Missing keys are then replaced by
+// random ones that only live as long as the process, so tokens and ticket
+// codes stop working on every restart. Never set it in production.
+func InsecureDevKeys() bool {
+	allowed, _ := strconv.ParseBool(os.Getenv("INSECURE_DEV_KEYS"))
+	return allowed
+}
+
+// randomDevKey returns a fresh random key for InsecureDevKeys mode.
+func randomDevKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// TicketCodeSecret returns the HMAC key used to sign ticket codes, read from
+// the TICKET_CODE_SECRET environment variable. Without it the server refuses
+// to start unless InsecureDevKeys is set, in which case a random key is
+// returned on every call, so callers must keep the result.
+func TicketCodeSecret() ([]byte, error) {
+	if secret := os.Getenv("TICKET_CODE_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	if !InsecureDevKeys() {
+		return nil, errors.New("TICKET_CODE_SECRET is not set, set INSECURE_DEV_KEYS=true to use a temporary key in development")
+	}
+	log.Println("TICKET_CODE_SECRET is not set, using a temporary random key")
+	return randomDevKey()
+}
@@ -140,3 +140,11 @@ func Logout(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
 	}
 }
+
+// currentUser loads the user identified by the token that AuthMiddleware
+// accepted for this request.
+func currentUser(db *gorm.DB, c *gin.Context) (models.User, error) {
+	var user models.User
+	err := db.Where("email = ?", c.GetString("email")).First(&user).Error
+	return user, err
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ticketink/models"
+	"ticketink/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// calendarHistory is how long past events stay in the public feed.
+const calendarHistory = 30 * 24 * time.Hour
+
+func calendarEvent(event models.Event, baseURL string) utils.CalendarEvent {
+	location := event.Location
+	if event.Venue != nil && event.Venue.Name != location {
+		location = strings.Trim(event.Venue.Name+", "+location, ", ")
+	}
+	id := strconv.FormatUint(uint64(event.ID), 10)
+	return utils.CalendarEvent{
+		UID:          "event-" + id + "@ticketink",
+		Summary:      event.Title,
+		Description:  event.Description,
+		Location:     location,
+		URL:          baseURL + "/events/" + id,
+		Start:        event.StartsAt,
+		End:          event.EndsAt,
+		LastModified: event.UpdatedAt,
+		Sequence:     event.ScheduleVersion,
+		Cancelled:    event.Status == models.EventStatusCancelled,
+	}
+}
+
+// requestBaseURL is the scheme and host the request was made to, used to
+// build absolute links.
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func writeCalendar(c *gin.Context, name string, events []models.Event) {
+	baseURL := requestBaseURL(c)
+	calendarEvents := make([]utils.CalendarEvent, len(events))
+	for i, event := range events {
+		calendarEvents[i] = calendarEvent(event, baseURL)
+	}
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := utils.WriteCalendar(c.Writer, name, calendarEvents); err != nil {
+		c.Error(err)
+	}
+}
+
+// GetEventsCalendar is the public catalog as an iCalendar feed. Cancelled
+// events stay in it, marked cancelled, so subscribers see them go away.
+func GetEventsCalendar(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var events []models.Event
+		if err := db.Scopes(models.Published).Preload("Venue").
+			Where("ends_at >= ?", time.Now().Add(-calendarHistory)).
+			Order("starts_at, id").Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+			return
+		}
+
+		writeCalendar(c, "ticketink events", events)
+	}
+}
+
+// GetUserCalendar serves /calendar/:token.ics, the events a user holds
+// purchased tickets for. Events called off after the user bought tickets
+// are included as cancelled.
+func GetUserCalendar(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutSuffix(c.Param("token"), ".ics")
+		if !ok || token == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+			return
+		}
+
+		var feed models.CalendarFeed
+		if err := db.Where("token = ?", token).First(&feed).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+			return
+		}
+
+		held := db.Model(&models.Ticket{}).Select("tickets.event_id").
+			Joins("JOIN events ON events.id = tickets.event_id").
+			Where("tickets.user_id = ?", feed.UserID).
+			Where("tickets.status = ? OR (tickets.status = ? AND events.status = ?)", "purchased", "cancelled", models.EventStatusCancelled)
+
+		var events []models.Event
+		if err := db.Preload("Venue").Where("id IN (?)", held).Order("starts_at, id").Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+			return
+		}
+
+		writeCalendar(c, "My ticketink events", events)
+	}
+}
+
+func newCalendarToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func calendarFeedResponse(c *gin.Context, feed models.CalendarFeed) gin.H {
+	return gin.H{"url": requestBaseURL(c) + "/calendar/" + feed.Token + ".ics"}
+}
+
+// GetCalendarFeed returns the current user's calendar URL, creating the
+// feed on first use.
+func GetCalendarFeed(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var feed models.CalendarFeed
+		if err := db.Where("user_id = ?", user.ID).First(&feed).Error; err != nil {
+			token, err := newCalendarToken()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar"})
+				return
+			}
+			feed = models.CalendarFeed{UserID: user.ID, Token: token}
+			if err := db.Create(&feed).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, calendarFeedResponse(c, feed))
+	}
+}
+
+// ResetCalendarFeed replaces the current user's calendar token, so the old
+// URL stops working.
+func ResetCalendarFeed(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		token, err := newCalendarToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset calendar"})
+			return
+		}
+
+		feed := models.CalendarFeed{UserID: user.ID}
+		db.Where("user_id = ?", user.ID).First(&feed)
+		feed.Token = token
+		if err := db.Save(&feed).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset calendar"})
+			return
+		}
+
+		c.JSON(http.StatusOK, calendarFeedResponse(c, feed))
+	}
+}
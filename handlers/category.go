@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CategoryRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"max=100"` // derived from Name when empty
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slugify(value string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(value), "-"), "-")
+}
+
+// categoryFilter restricts an event query to events in any of the given
+// comma-separated categories, each given by ID or slug.
+func categoryFilter(db *gorm.DB, categories string) *gorm.DB {
+	var ids []uint
+	var slugs []string
+	for _, category := range strings.Split(categories, ",") {
+		category = strings.TrimSpace(category)
+		if id, err := strconv.ParseUint(category, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		} else if category != "" {
+			slugs = append(slugs, slugify(category))
+		}
+	}
+
+	return db.Table("event_categories").
+		Select("event_categories.event_id").
+		Joins("JOIN categories ON categories.id = event_categories.category_id").
+		Where("categories.id IN ? OR categories.slug IN ?", ids, slugs)
+}
+
+// categoryCounts returns how many of the events matched by eventQuery fall in
+// each category.
+func categoryCounts(db *gorm.DB, eventQuery *gorm.DB) []models.CategoryCount {
+	counts := []models.CategoryCount{}
+	db.Table("categories").
+		Select("categories.id, categories.name, categories.slug, COUNT(event_categories.event_id) AS events").
+		Joins("JOIN event_categories ON event_categories.category_id = categories.id").
+		Where("event_categories.event_id IN (?)", eventQuery.Select("events.id")).
+		Group("categories.id, categories.name, categories.slug").
+		Order("categories.name").
+		Scan(&counts)
+	return counts
+}
+
+func loadCategories(db *gorm.DB, ids []uint) ([]models.Category, bool) {
+	categories := []models.Category{}
+	if len(ids) == 0 {
+		return categories, true
+	}
+	if err := db.Where("id IN ?", ids).Find(&categories).Error; err != nil {
+		return nil, false
+	}
+	return categories, len(categories) == len(ids)
+}
+
+func ListCategories(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		counts := []models.CategoryCount{}
+		db.Table("categories").
+			Select("categories.id, categories.name, categories.slug, COUNT(event_categories.event_id) AS events").
+			Joins("LEFT JOIN event_categories ON event_categories.category_id = categories.id").
+			Group("categories.id, categories.name, categories.slug").
+			Order("categories.name").
+			Scan(&counts)
+
+		c.JSON(http.StatusOK, gin.H{"categories": counts})
+	}
+}
+
+func CreateCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		category := models.Category{Name: strings.TrimSpace(req.Name), Slug: slugify(req.Slug)}
+		if category.Slug == "" {
+			category.Slug = slugify(req.Name)
+		}
+
+		var existing models.Category
+		if err := db.Where("name = ? OR slug = ?", category.Name, category.Slug).First(&existing).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Category name and slug must be unique"})
+			return
+		}
+
+		if err := db.Create(&category).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, category)
+	}
+}
+
+func UpdateCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var category models.Category
+		if err := db.First(&category, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+			return
+		}
+
+		var req CategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		category.Name = strings.TrimSpace(req.Name)
+		category.Slug = slugify(req.Slug)
+		if category.Slug == "" {
+			category.Slug = slugify(req.Name)
+		}
+
+		var existing models.Category
+		if err := db.Where("(name = ? OR slug = ?) AND id <> ?", category.Name, category.Slug, category.ID).First(&existing).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Category name and slug must be unique"})
+			return
+		}
+
+		if err := db.Save(&category).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+			return
+		}
+
+		c.JSON(http.StatusOK, category)
+	}
+}
+
+func DeleteCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var category models.Category
+		if err := db.First(&category, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM event_categories WHERE category_id = ?", category.ID).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&category).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ticketink/models"
+	"ticketink/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// scanError is a rejected admission. Reason is a stable machine-readable code
+// that gate devices can switch on, Message is meant for the gate staff.
+type scanError struct {
+	Status  int
+	Reason  string
+	Message string
+}
+
+func (e *scanError) Error() string {
+	return e.Message
+}
+
+var (
+	errScanInvalidCode    = &scanError{http.StatusBadRequest, "invalid_code", "Ticket code is not valid"}
+	errScanWrongEvent     = &scanError{http.StatusBadRequest, "wrong_event", "Ticket is for a different event"}
+	errScanRevoked        = &scanError{http.StatusBadRequest, "code_revoked", "Ticket code has been replaced, ask the holder for their current ticket"}
+	errScanCancelled      = &scanError{http.StatusBadRequest, "cancelled", "Ticket has been cancelled"}
+	errScanNotPurchased   = &scanError{http.StatusBadRequest, "not_purchased", "Ticket has not been paid for"}
+	errScanAlreadyScanned = &scanError{http.StatusConflict, "already_checked_in", "Ticket has already been checked in"}
+)
+
+func CheckInTicket(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Code    string `json:"code" binding:"required"`
+			EventID uint   `json:"event_id" binding:"required"`
+			Gate    string `json:"gate" binding:"required,max=50"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		payload, err := utils.ParseTicketCode(req.Code)
+		if err != nil {
+			c.JSON(errScanInvalidCode.Status, gin.H{"error": errScanInvalidCode.Message, "reason": errScanInvalidCode.Reason})
+			return
+		}
+
+		var checkIn models.CheckIn
+		var previous models.CheckIn
+		var ticket models.Ticket
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket, payload.TicketID).Error; err != nil {
+				return errScanInvalidCode
+			}
+			if ticket.EventID != req.EventID || payload.EventID != req.EventID {
+				return errScanWrongEvent
+			}
+			if ticket.UserID != payload.UserID || ticket.CodeVersion != payload.Version {
+				return errScanRevoked
+			}
+			if ticket.Status == "cancelled" {
+				return errScanCancelled
+			}
+			if ticket.Status != "purchased" {
+				return errScanNotPurchased
+			}
+			if err := tx.Where("ticket_id = ?", ticket.ID).First(&previous).Error; err == nil {
+				return errScanAlreadyScanned
+			}
+
+			checkIn = models.CheckIn{
+				TicketID:    ticket.ID,
+				EventID:     ticket.EventID,
+				Gate:        req.Gate,
+				ScannedBy:   c.GetString("email"),
+				CheckedInAt: time.Now(),
+			}
+			return tx.Create(&checkIn).Error
+		})
+		if err != nil {
+			var serr *scanError
+			if !errors.As(err, &serr) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in ticket"})
+				return
+			}
+			response := gin.H{"error": serr.Message, "reason": serr.Reason}
+			if serr == errScanAlreadyScanned {
+				response["checked_in_at"] = previous.CheckedInAt
+				response["gate"] = previous.Gate
+			}
+			c.JSON(serr.Status, response)
+			return
+		}
+
+		db.Preload("User").Preload("TicketType").First(&ticket, ticket.ID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Ticket checked in successfully",
+			"check_in": checkIn,
+			"holder":   ticket.User.Name,
+			"tier":     ticket.TicketType,
+		})
+	}
+}
+
+func GetEventAttendance(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var event models.Event
+		if err := db.First(&event, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		var ticketsSold, checkedIn int64
+		db.Model(&models.Ticket{}).Where("event_id = ? AND status = ?", event.ID, "purchased").Count(&ticketsSold)
+		db.Model(&models.CheckIn{}).Where("event_id = ?", event.ID).Count(&checkedIn)
+
+		type gateCount struct {
+			Gate      string    `json:"gate"`
+			CheckedIn int64     `json:"checked_in"`
+			LastScan  time.Time `json:"last_scan"`
+		}
+		gates := []gateCount{}
+		db.Model(&models.CheckIn{}).
+			Select("gate, COUNT(*) AS checked_in, MAX(checked_in_at) AS last_scan").
+			Where("event_id = ?", event.ID).
+			Group("gate").
+			Scan(&gates)
+
+		c.JSON(http.StatusOK, gin.H{
+			"event_id":       event.ID,
+			"event_title":    event.Title,
+			"capacity":       event.Capacity,
+			"tickets_sold":   ticketsSold,
+			"checked_in":     checkedIn,
+			"not_checked_in": ticketsSold - checkedIn,
+			"gates":          gates,
+		})
+	}
+}
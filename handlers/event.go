@@ -1,59 +1,323 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"ticketink/models"
+	"ticketink/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type EventRequest struct {
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Date        string  `json:"date"`
-	Location    string  `json:"location"`
-	Price       float64 `json:"price"`
-	Capacity    int64   `json:"capacity"`
-	Status      string  `json:"status"` // Active, Ongoing, Finished
+	Title             string  `json:"title"`
+	Description       string  `json:"description"`
+	StartsAt          string  `json:"starts_at"`   // RFC 3339, or local time in time_zone without an offset
+	EndsAt            string  `json:"ends_at"`     // RFC 3339, or local time in time_zone without an offset
+	TimeZone          string  `json:"time_zone"`   // IANA name, defaults to UTC
+	SalesStart        string  `json:"sales_start"` // optional, same formats as starts_at
+	SalesEnd          string  `json:"sales_end"`   // optional, same formats as starts_at
+	Location          string  `json:"location"`
+	Price             float64 `json:"price"`
+	Capacity          int64   `json:"capacity"`
+	MaxTicketsPerUser int64   `json:"max_tickets_per_user" binding:"min=0"` // 0 means no limit
+	Status            string  `json:"status"`                               // read-only: events start as drafts and change through PublishEvent and UpdateEventStatus
+	RefundsDisabled   bool    `json:"refunds_disabled"`
+	RefundCutoffDays  int     `json:"refund_cutoff_days" binding:"min=0"`
+	CategoryIDs       []uint  `json:"category_ids"`
+	VenueID           *uint   `json:"venue_id"`
 }
 
-func ListEvents(db *gorm.DB) gin.HandlerFunc {
+// parseEventTime accepts an RFC 3339 timestamp, or a timestamp without an
+// offset which is then read as wall-clock time in the event's time zone.
+func parseEventTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", value, loc)
+}
+
+// schedule parses the requested start, end and time zone, falling back to the
+// current values for fields left empty.
+func (req EventRequest) schedule(current models.Event) (time.Time, time.Time, string, error) {
+	timeZone := current.TimeZone
+	if req.TimeZone != "" {
+		timeZone = req.TimeZone
+	}
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", errors.New("Unknown time_zone, use an IANA name such as Asia/Jakarta")
+	}
+
+	startsAt, endsAt := current.StartsAt, current.EndsAt
+	if req.StartsAt != "" {
+		if startsAt, err = parseEventTime(req.StartsAt, loc); err != nil {
+			return time.Time{}, time.Time{}, "", errors.New("Invalid starts_at format, use RFC 3339")
+		}
+	}
+	if req.EndsAt != "" {
+		if endsAt, err = parseEventTime(req.EndsAt, loc); err != nil {
+			return time.Time{}, time.Time{}, "", errors.New("Invalid ends_at format, use RFC 3339")
+		}
+	}
+
+	if startsAt.IsZero() || endsAt.IsZero() {
+		return time.Time{}, time.Time{}, "", errors.New("starts_at and ends_at are required")
+	}
+	if !endsAt.After(startsAt) {
+		return time.Time{}, time.Time{}, "", errors.New("ends_at must be after starts_at")
+	}
+	return startsAt, endsAt, timeZone, nil
+}
+
+// salesWindow parses the optional sales window in the event's time zone.
+func (req EventRequest) salesWindow(timeZone string, startsAt time.Time) (*time.Time, *time.Time, error) {
+	loc, _ := time.LoadLocation(timeZone)
+
+	var salesStart, salesEnd *time.Time
+	if req.SalesStart != "" {
+		t, err := parseEventTime(req.SalesStart, loc)
+		if err != nil {
+			return nil, nil, errors.New("Invalid sales_start format, use RFC 3339")
+		}
+		salesStart = &t
+	}
+	if req.SalesEnd != "" {
+		t, err := parseEventTime(req.SalesEnd, loc)
+		if err != nil {
+			return nil, nil, errors.New("Invalid sales_end format, use RFC 3339")
+		}
+		salesEnd = &t
+	}
+
+	if salesStart != nil && salesEnd != nil && !salesEnd.After(*salesStart) {
+		return nil, nil, errors.New("sales_end must be after sales_start")
+	}
+	if salesStart != nil && !salesStart.Before(startsAt) {
+		return nil, nil, errors.New("sales_start must be before the event starts")
+	}
+	return salesStart, salesEnd, nil
+}
+
+// eventListing is an event as returned by ListEvents, with its current sales
+// state and, for upcoming on-sales, the countdown until tickets go on sale.
+type eventListing struct {
+	models.Event
+	SalesStatus         string `json:"sales_status"`
+	SalesOpensInSeconds *int64 `json:"sales_opens_in_seconds,omitempty"`
+}
+
+func newEventListing(event models.Event, now time.Time) eventListing {
+	listing := eventListing{Event: event, SalesStatus: event.SalesStatus(now)}
+	if listing.SalesStatus == models.SalesNotYetOpen {
+		seconds := int64(event.SalesStart.Sub(now).Seconds())
+		listing.SalesOpensInSeconds = &seconds
+	}
+	return listing
+}
+
+// EventListQuery holds the query parameters accepted by ListEvents.
+type EventListQuery struct {
+	Page     int      `form:"page" binding:"omitempty,min=1"`
+	Limit    int      `form:"limit" binding:"omitempty,min=1,max=100"`
+	Category string   `form:"category"`
+	Status   string   `form:"status"`
+	Search   string   `form:"search" binding:"max=100"`
+	Location string   `form:"location" binding:"max=100"` // matches the location text or the venue name
+	From     string   `form:"from"`                       // RFC 3339 or YYYY-MM-DD, inclusive
+	To       string   `form:"to"`                         // RFC 3339 or YYYY-MM-DD, a date includes the whole day
+	MinPrice *float64 `form:"min_price" binding:"omitempty,min=0"`
+	MaxPrice *float64 `form:"max_price" binding:"omitempty,min=0"`
+	Sort     string   `form:"sort" binding:"omitempty,oneof=date price newest popularity remaining"`
+	Order    string   `form:"order" binding:"omitempty,oneof=asc desc"`
+	GroupBy  string   `form:"group_by" binding:"omitempty,oneof=series"`
+}
+
+type eventSort struct {
+	Column string
+	Desc   bool // default direction when no order is given
+}
+
+var eventSorts = map[string]eventSort{
+	"date":       {Column: "events.starts_at"},
+	"price":      {Column: "events.price"},
+	"newest":     {Column: "events.created_at", Desc: true},
+	"popularity": {Column: "COALESCE(sales.sold, 0)", Desc: true},
+	"remaining":  {Column: "events.capacity - COALESCE(holds.held, 0)", Desc: true},
+}
+
+// parseListTime parses a from/to bound. A bare date is read as midnight
+// UTC; with endOfDay it instead points at the start of the following day.
+func parseListTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// orderClause returns the ORDER BY for the requested sort. Every sort ends
+// with the event ID so pages stay stable when sort values tie.
+func (q EventListQuery) orderClause() string {
+	sort := eventSorts[q.Sort]
+	desc := sort.Desc
+	if q.Order != "" {
+		desc = q.Order == "desc"
+	}
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	if q.Sort == "date" {
+		return "events.starts_at " + direction + ", events.id " + direction
+	}
+	return sort.Column + " " + direction + ", events.starts_at, events.id"
+}
+
+// filterEvents applies the filters of an event listing, other than the
+// category filter, to a query on events.
+func filterEvents(db *gorm.DB, params EventListQuery) (*gorm.DB, error) {
+	if params.MinPrice != nil && params.MaxPrice != nil && *params.MinPrice > *params.MaxPrice {
+		return nil, errors.New("min_price must not be greater than max_price")
+	}
+
+	query := db.Model(&models.Event{})
+
+	if params.Status != "" {
+		query = query.Where("events.status = ?", params.Status)
+	}
+	if params.Search != "" {
+		query = query.Where("(events.title LIKE ? OR events.description LIKE ?)", "%"+params.Search+"%", "%"+params.Search+"%")
+	}
+	if params.Location != "" {
+		venues := db.Model(&models.Venue{}).Select("id").Where("name LIKE ?", "%"+params.Location+"%")
+		query = query.Where("(events.location LIKE ? OR events.venue_id IN (?))", "%"+params.Location+"%", venues)
+	}
+	if params.From != "" {
+		from, err := parseListTime(params.From, false)
+		if err != nil {
+			return nil, errors.New("Invalid from format, use RFC 3339 or YYYY-MM-DD")
+		}
+		query = query.Where("events.starts_at >= ?", from)
+	}
+	if params.To != "" {
+		to, err := parseListTime(params.To, true)
+		if err != nil {
+			return nil, errors.New("Invalid to format, use RFC 3339 or YYYY-MM-DD")
+		}
+		query = query.Where("events.starts_at < ?", to)
+	}
+	if params.MinPrice != nil {
+		query = query.Where("events.price >= ?", *params.MinPrice)
+	}
+	if params.MaxPrice != nil {
+		query = query.Where("events.price <= ?", *params.MaxPrice)
+	}
+	return query.Session(&gorm.Session{}), nil
+}
+
+// sortedEvents orders the query as requested, joining the ticket counts the
+// popularity and remaining sorts need.
+func sortedEvents(db *gorm.DB, query *gorm.DB, params EventListQuery) *gorm.DB {
+	query = query.Select("events.*")
+	switch params.Sort {
+	case "popularity":
+		sold := db.Model(&models.Ticket{}).Select("event_id, COUNT(*) AS sold").Where("status = ?", "purchased").Group("event_id")
+		query = query.Joins("LEFT JOIN (?) AS sales ON sales.event_id = events.id", sold)
+	case "remaining":
+		held := db.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Select("event_id, COUNT(*) AS held").Group("event_id")
+		query = query.Joins("LEFT JOIN (?) AS holds ON holds.event_id = events.id", held)
+	}
+	return query.Order(params.orderClause())
+}
+
+func ListEvents(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		params := EventListQuery{Page: 1, Limit: 10, Sort: "date"}
+		if err := c.ShouldBindQuery(&params); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page, limit := params.Page, params.Limit
 		offset := (page - 1) * limit
 
-		category := c.Query("category")
-		status := c.Query("status")
-		search := c.Query("search")
+		query, err := filterEvents(db, params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Only admins see drafts, so they can find them with ?status=draft.
+		if c.GetString("role") != "admin" {
+			query = query.Scopes(models.Published).Session(&gorm.Session{})
+		}
 
-		query := db.Model(&models.Event{})
+		// Category counts ignore the category filter itself so clients can
+		// show how many results every other category would give.
+		categories := categoryCounts(db, query)
 
-		if category != "" {
-			query = query.Where("category = ?", category)
-		}
-		if status != "" {
-			query = query.Where("status = ?", status)
+		if params.Category != "" {
+			query = query.Where("events.id IN (?)", categoryFilter(db, params.Category)).Session(&gorm.Session{})
 		}
-		if search != "" {
-			query = query.Where("title LIKE ? OR description LIKE ?", "%"+search+"%", "%"+search+"%")
+		ungrouped := query
+
+		// With group_by=series every series is listed once, represented by its
+		// earliest matching occurrence, and the remaining matching
+		// occurrences are returned under "series".
+		groupBySeries := params.GroupBy == "series"
+		if groupBySeries {
+			firstOccurrences := query.Select("MIN(events.id)").Where("events.series_id IS NOT NULL").Group("events.series_id")
+			query = query.Where("events.series_id IS NULL OR events.id IN (?)", firstOccurrences).Session(&gorm.Session{})
 		}
 
 		var totalItems int64
 		query.Count(&totalItems)
 
 		var events []models.Event
-		query.Limit(limit).Offset(offset).Find(&events)
+		sortedEvents(db, query, params).Preload("Categories").Preload("Media", orderedMedia).Limit(limit).Offset(offset).Find(&events)
+
+		now := time.Now()
+		listings := make([]eventListing, len(events))
+		for i, event := range events {
+			withMediaURLs(store, event.Media)
+			listings[i] = newEventListing(event, now)
+		}
+
+		series := gin.H{}
+		if groupBySeries {
+			for _, event := range events {
+				if event.SeriesID == nil {
+					continue
+				}
+				var occurrences []models.Event
+				ungrouped.Where("events.series_id = ?", *event.SeriesID).Order("events.starts_at").Find(&occurrences)
+				series[strconv.FormatUint(uint64(*event.SeriesID), 10)] = occurrences
+			}
+		}
 
 		totalPages := (int(totalItems) + limit - 1) / limit
 
 		c.JSON(http.StatusOK, gin.H{
-			"events": events,
+			"events":     listings,
+			"sort":       params.Sort,
+			"categories": categories,
+			"series":     series,
 			"pagination": gin.H{
 				"current_page": page,
 				"total_pages":  totalPages,
@@ -63,6 +327,27 @@ func ListEvents(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+func GetEvent(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		query := db
+		if c.GetString("role") != "admin" {
+			query = query.Scopes(models.Published)
+		}
+
+		var event models.Event
+		err := query.Preload("Categories").Preload("TicketTypes").Preload("Venue").Preload("Media", orderedMedia).First(&event, id).Error
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		withMediaURLs(store, event.Media)
+
+		c.JSON(http.StatusOK, newEventListing(event, time.Now()))
+	}
+}
+
 func CreateEvent(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req EventRequest
@@ -72,26 +357,59 @@ func CreateEvent(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var existingEvent models.Event
-		if err := db.Where("title = ?", req.Title).First(&existingEvent).Error; err == nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Event title must be unique"})
+		startsAt, endsAt, timeZone, err := req.schedule(models.Event{})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !startsAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "starts_at must be in the future"})
 			return
 		}
 
-		eventDate, err := time.Parse("2006-01-02", req.Date)
+		salesStart, salesEnd, err := req.salesWindow(timeZone, startsAt)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format, use YYYY-MM-DD"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		var existingEvent models.Event
+		if err := db.Where("title = ? AND starts_at = ?", req.Title, startsAt).First(&existingEvent).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An event with this title already starts at that time"})
+			return
+		}
+
+		categories, ok := loadCategories(db, req.CategoryIDs)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown category in category_ids"})
+			return
+		}
+
+		if req.VenueID != nil {
+			var venue models.Venue
+			if err := db.First(&venue, *req.VenueID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Venue not found"})
+				return
+			}
+		}
+
 		event := models.Event{
-			Title:       req.Title,
-			Description: req.Description,
-			Date:        eventDate,
-			Location:    req.Location,
-			Price:       req.Price,
-			Capacity:    req.Capacity,
-			Status:      "Active",
+			Title:             req.Title,
+			Description:       req.Description,
+			StartsAt:          startsAt,
+			EndsAt:            endsAt,
+			TimeZone:          timeZone,
+			SalesStart:        salesStart,
+			SalesEnd:          salesEnd,
+			Location:          req.Location,
+			VenueID:           req.VenueID,
+			Price:             req.Price,
+			Capacity:          req.Capacity,
+			MaxTicketsPerUser: req.MaxTicketsPerUser,
+			Status:            models.EventStatusDraft,
+			RefundsDisabled:   req.RefundsDisabled,
+			RefundCutoffDays:  req.RefundCutoffDays,
+			Categories:        categories,
 		}
 
 		if err := db.Create(&event).Error; err != nil {
@@ -103,38 +421,129 @@ func CreateEvent(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+var (
+	errEventNotEditable = &purchaseError{Status: http.StatusBadRequest, Message: "Cannot update an event that has already started, finished or was cancelled"}
+	errVenueHasSales    = &purchaseError{Status: http.StatusBadRequest, Message: "Cannot change the venue of an event with sold tickets"}
+)
+
 func UpdateEvent(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 
-		var event models.Event
-		if err := db.First(&event, id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-			return
-		}
-
 		var req EventRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if strings.EqualFold(event.Status, "finished") || event.Date.Before(time.Now()) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot update an event that has already started or finished"})
+		categories, ok := loadCategories(db, req.CategoryIDs)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown category in category_ids"})
 			return
 		}
 
-		event.Title = req.Title
-		event.Description = req.Description
-		event.Location = req.Location
-		event.Price = req.Price
-		event.Capacity = req.Capacity
+		if req.VenueID != nil {
+			var venue models.Venue
+			if err := db.First(&venue, *req.VenueID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Venue not found"})
+				return
+			}
+		}
 
-		if err := db.Save(&event).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		var event models.Event
+		var capacityRaised bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// The event is re-read under lock so that a cancellation,
+			// publication or lifecycle transition that commits meanwhile is
+			// checked against instead of being overwritten.
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errEventNotFound
+				}
+				return lockError(err)
+			}
+
+			// Drafts are not visible to anyone yet, so they can be edited even
+			// when the schedule they were drafted with has already passed.
+			now := time.Now()
+			if event.Closed() || (event.Status != models.EventStatusDraft && event.HasStarted(now)) {
+				return errEventNotEditable
+			}
+
+			startsAt, endsAt, timeZone, err := req.schedule(event)
+			if err != nil {
+				return &purchaseError{Status: http.StatusBadRequest, Message: err.Error()}
+			}
+			if !startsAt.After(now) {
+				return &purchaseError{Status: http.StatusBadRequest, Message: "starts_at must be in the future"}
+			}
+
+			salesStart, salesEnd, err := req.salesWindow(timeZone, startsAt)
+			if err != nil {
+				return &purchaseError{Status: http.StatusBadRequest, Message: err.Error()}
+			}
+
+			if req.Capacity < event.Capacity {
+				tierCapacity, err := eventTierCapacity(tx, event.ID)
+				if err != nil {
+					return err
+				}
+				if req.Capacity < tierCapacity {
+					return &purchaseError{Status: http.StatusBadRequest, Message: errTierCapacityExceeded.Error()}
+				}
+			}
+
+			capacityRaised = req.Capacity > event.Capacity
+			before := event
+
+			event.Title = req.Title
+			event.Description = req.Description
+			event.StartsAt = startsAt
+			event.EndsAt = endsAt
+			event.TimeZone = timeZone
+			event.SalesStart = salesStart
+			event.SalesEnd = salesEnd
+			event.Location = req.Location
+			if req.VenueID != nil && venueChanged(event.VenueID, req.VenueID) {
+				var ticketsSold int64
+				if err := tx.Model(&models.Ticket{}).Where("event_id = ?", event.ID).Count(&ticketsSold).Error; err != nil {
+					return err
+				}
+				if ticketsSold > 0 {
+					return errVenueHasSales
+				}
+				event.VenueID = req.VenueID
+			}
+			event.Price = req.Price
+			event.Capacity = req.Capacity
+			event.MaxTicketsPerUser = req.MaxTicketsPerUser
+			event.RefundsDisabled = req.RefundsDisabled
+			event.RefundCutoffDays = req.RefundCutoffDays
+			event.TrackScheduleChange(before)
+
+			// Status and publication only change through their own
+			// endpoints and the scheduler.
+			if err := tx.Omit("Status", "PublishAt", "PublishedAt").Save(&event).Error; err != nil {
+				return err
+			}
+			// A missing category_ids leaves the categories alone, an empty
+			// list clears them.
+			if req.CategoryIDs != nil {
+				return tx.Model(&event).Association("Categories").Replace(categories)
+			}
+			return tx.Model(&event).Association("Categories").Find(&event.Categories)
+		})
+		if err != nil {
+			respondPurchaseError(c, err, "Failed to update event")
 			return
 		}
 
+		if capacityRaised {
+			if err := models.OfferWaitlistSeats(db, event.ID); err != nil {
+				log.Println("Failed to offer new capacity to waitlist:", err)
+			}
+		}
+
 		c.JSON(http.StatusOK, event)
 	}
 }
@@ -144,34 +553,271 @@ func UpdateEventStatus(db *gorm.DB) gin.HandlerFunc {
 
 		id := c.Param("id")
 
+		var req struct {
+			Status string `json:"status" binding:"required,oneof=ongoing finished"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The transition is checked against the locked row, so a concurrent
+		// cancellation or scheduler transition is not overwritten.
+		var event models.Event
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errEventNotFound
+				}
+				return lockError(err)
+			}
+			if event.Closed() {
+				return &purchaseError{Status: http.StatusBadRequest, Message: "Event is already " + event.Status + " and cannot be updated"}
+			}
+			if err := event.TransitionTo(tx, req.Status, c.GetString("email")); err != nil {
+				if errors.Is(err, models.ErrInvalidTransition) {
+					return &purchaseError{Status: http.StatusBadRequest, Message: "Cannot change event status from " + event.Status + " to " + req.Status}
+				}
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			respondPurchaseError(c, err, "Failed to update event status")
+			return
+		}
+
+		c.JSON(http.StatusOK, event)
+	}
+}
+
+// PublishEvent makes a draft visible to buyers, either right away or, when
+// publish_at lies in the future, at that time through the scheduler.
+// Publishing a draft that already has a publish_at moves it to the new time.
+func PublishEvent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var req struct {
+			PublishAt string `json:"publish_at"` // RFC 3339, or local time in the event's time zone
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var event models.Event
+		if err := db.First(&event, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		if event.Status != models.EventStatusDraft {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft events can be published"})
+			return
+		}
+
+		now := time.Now()
+		if event.HasStarted(now) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot publish an event whose start time has passed, reschedule it first"})
+			return
+		}
+		if event.Capacity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot publish an event without capacity"})
+			return
+		}
+
+		publishAt := now
+		if req.PublishAt != "" {
+			var err error
+			if publishAt, err = parseEventTime(req.PublishAt, event.Zone()); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid publish_at format, use RFC 3339"})
+				return
+			}
+			if !publishAt.Before(event.StartsAt) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be before the event starts"})
+				return
+			}
+		}
+
+		if publishAt.After(now) {
+			if err := db.Model(&event).Update("publish_at", publishAt).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule publication"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Event scheduled for publication", "event": event})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, event.ID).Error; err != nil {
+				return err
+			}
+			return event.Publish(tx, c.GetString("email"))
+		})
+		if errors.Is(err, models.ErrInvalidTransition) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft events can be published"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish event"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Event published successfully", "event": event})
+	}
+}
+
+func GetEventStatusHistory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
 		var event models.Event
 		if err := db.First(&event, id).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 			return
 		}
 
+		var transitions []models.EventStatusTransition
+		db.Where("event_id = ?", event.ID).Order("id").Find(&transitions)
+
+		c.JSON(http.StatusOK, gin.H{
+			"event_id":    event.ID,
+			"status":      event.Status,
+			"transitions": transitions,
+		})
+	}
+}
+
+// CancelEvent calls off an event: every active ticket is cancelled, paid
+// tickets get an approved full refund regardless of the refund policy,
+// pending orders, waitlist entries and transfers are closed, and each ticket
+// holder is queued a notification. It all happens in one transaction and is
+// recorded in the event's status history with the given reason.
+func CancelEvent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
 		var req struct {
-			Status string `json:"status" binding:"required,oneof=ongoing finished"`
+			Reason string `json:"reason" binding:"required,max=1000"`
 		}
-
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if strings.EqualFold(event.Status, "finished") {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Event is already finished and cannot be updated"})
+		actor := c.GetString("email")
+		now := time.Now()
+
+		var event models.Event
+		var cancelledTickets int64
+		var refunds []models.Refund
+		var notifications []models.Notification
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+				return errEventNotFound
+			}
+			if err := event.TransitionWithReason(tx, models.EventStatusCancelled, actor, req.Reason); err != nil {
+				return err
+			}
+
+			var tickets []models.Ticket
+			if err := tx.Preload("User").Where("event_id = ? AND status IN ?", event.ID, []string{"purchased", "reserved"}).Find(&tickets).Error; err != nil {
+				return err
+			}
+
+			refunded := map[uint]float64{}
+			holders := []models.User{}
+			for _, ticket := range tickets {
+				if _, seen := refunded[ticket.UserID]; !seen {
+					holders = append(holders, ticket.User)
+					refunded[ticket.UserID] = 0
+				}
+				if ticket.Status != "purchased" || ticket.PaidAt == nil || ticket.Price <= 0 {
+					continue
+				}
+				refunds = append(refunds, models.Refund{
+					TicketID:   ticket.ID,
+					UserID:     ticket.UserID,
+					EventID:    event.ID,
+					Amount:     ticket.Price,
+					Reason:     "Event cancelled: " + req.Reason,
+					Status:     "approved",
+					ReviewedBy: actor,
+					ApprovedAt: &now,
+				})
+				refunded[ticket.UserID] += ticket.Price
+			}
+			if len(refunds) > 0 {
+				if err := tx.Create(&refunds).Error; err != nil {
+					return err
+				}
+			}
+
+			result := tx.Model(&models.Ticket{}).
+				Where("event_id = ? AND status IN ?", event.ID, []string{"purchased", "reserved"}).
+				Updates(map[string]interface{}{"status": "cancelled", "expires_at": nil})
+			if result.Error != nil {
+				return result.Error
+			}
+			cancelledTickets = result.RowsAffected
+
+			if err := tx.Model(&models.Order{}).Where("event_id = ? AND status = ?", event.ID, "pending").
+				Updates(map[string]interface{}{"status": "cancelled", "expires_at": nil}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.WaitlistEntry{}).Where("event_id = ? AND status IN ?", event.ID, []string{"waiting", "offered"}).
+				Updates(map[string]interface{}{"status": "cancelled", "offer_expires_at": nil}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.TicketTransfer{}).
+				Where("status = ? AND ticket_id IN (?)", "pending", tx.Model(&models.Ticket{}).Select("id").Where("event_id = ?", event.ID)).
+				Updates(map[string]interface{}{"status": "cancelled", "responded_at": now}).Error; err != nil {
+				return err
+			}
+
+			for _, holder := range holders {
+				body := fmt.Sprintf("%s, scheduled for %s, has been cancelled.\n\n%s\n\nYour tickets have been cancelled.",
+					event.Title, event.StartsAt.In(event.Zone()).Format("Mon 2 Jan 2006 15:04 MST"), req.Reason)
+				if amount := refunded[holder.ID]; amount > 0 {
+					body += fmt.Sprintf(" A refund of %.2f has been approved and will be paid back to you.", amount)
+				}
+				notifications = append(notifications, models.Notification{
+					UserID:  holder.ID,
+					Email:   holder.Email,
+					EventID: &event.ID,
+					Subject: "Cancelled: " + event.Title,
+					Body:    body,
+					Status:  "pending",
+				})
+			}
+			if len(notifications) > 0 {
+				return tx.Create(&notifications).Error
+			}
+			return nil
+		})
+		if errors.Is(err, models.ErrInvalidTransition) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot cancel an event that is already " + event.Status})
 			return
 		}
-
-		event.Status = req.Status
-
-		if err := db.Save(&event).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event status"})
+		if err != nil {
+			respondPurchaseError(c, err, "Failed to cancel event")
 			return
 		}
 
-		c.JSON(http.StatusOK, event)
+		var refundTotal float64
+		for _, refund := range refunds {
+			refundTotal += refund.Amount
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":              "Event cancelled successfully",
+			"event":                event,
+			"cancelled_tickets":    cancelledTickets,
+			"refunds":              len(refunds),
+			"refund_total":         refundTotal,
+			"notifications_queued": len(notifications),
+		})
 	}
 }
 
@@ -0,0 +1,601 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxImportSize = 5 << 20 // bytes
+	maxImportRows = 1000
+)
+
+// EventRecord is one event in a bulk import or export file. Rows with an ID
+// update that event, rows without one create a new draft. Status is written
+// on export for reference and ignored on import.
+type EventRecord struct {
+	ID                uint     `json:"id,omitempty"`
+	Title             string   `json:"title"`
+	Description       string   `json:"description"`
+	StartsAt          string   `json:"starts_at"`
+	EndsAt            string   `json:"ends_at"`
+	TimeZone          string   `json:"time_zone"`
+	SalesStart        string   `json:"sales_start"`
+	SalesEnd          string   `json:"sales_end"`
+	Location          string   `json:"location"`
+	VenueID           *uint    `json:"venue_id"`
+	Price             float64  `json:"price"`
+	Capacity          int64    `json:"capacity"`
+	MaxTicketsPerUser int64    `json:"max_tickets_per_user"`
+	RefundsDisabled   bool     `json:"refunds_disabled"`
+	RefundCutoffDays  int      `json:"refund_cutoff_days"`
+	Categories        []string `json:"categories"` // category slugs
+	Status            string   `json:"status,omitempty"`
+}
+
+// eventCSVColumns is the column order of exported CSV files. Imported files
+// may order the columns freely, and missing columns count as empty cells.
+var eventCSVColumns = []string{
+	"id", "title", "description", "starts_at", "ends_at", "time_zone", "sales_start", "sales_end",
+	"location", "venue_id", "price", "capacity", "max_tickets_per_user", "refunds_disabled",
+	"refund_cutoff_days", "categories", "status",
+}
+
+// csvListSeparator separates the category slugs within a CSV cell.
+const csvListSeparator = ";"
+
+type importRowError struct {
+	Row   int    `json:"row"` // 1-based, not counting the CSV header
+	Error string `json:"error"`
+}
+
+type importResult struct {
+	Row    int    `json:"row"`
+	Action string `json:"action"` // "create", "update" or "unchanged"
+	ID     uint   `json:"id,omitempty"`
+}
+
+// errImportRolledBack ends the import transaction without committing.
+var errImportRolledBack = errors.New("import rolled back")
+
+func newEventRecord(event models.Event) EventRecord {
+	zone := event.Zone()
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.In(zone).Format(time.RFC3339)
+	}
+
+	record := EventRecord{
+		ID:                event.ID,
+		Title:             event.Title,
+		Description:       event.Description,
+		StartsAt:          formatTime(&event.StartsAt),
+		EndsAt:            formatTime(&event.EndsAt),
+		TimeZone:          event.TimeZone,
+		SalesStart:        formatTime(event.SalesStart),
+		SalesEnd:          formatTime(event.SalesEnd),
+		Location:          event.Location,
+		VenueID:           event.VenueID,
+		Price:             event.Price,
+		Capacity:          event.Capacity,
+		MaxTicketsPerUser: event.MaxTicketsPerUser,
+		RefundsDisabled:   event.RefundsDisabled,
+		RefundCutoffDays:  event.RefundCutoffDays,
+		Categories:        []string{},
+		Status:            event.Status,
+	}
+	for _, category := range event.Categories {
+		record.Categories = append(record.Categories, category.Slug)
+	}
+	return record
+}
+
+// csvFormulaPrefixes are the leading characters that make spreadsheet
+// applications evaluate a cell as a formula.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCSVCell prefixes cells that would be evaluated as formulas with a
+// quote. Cells that already start with a quote get one too, so that
+// unescapeCSVCell restores every value exactly.
+func escapeCSVCell(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes+"'", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func unescapeCSVCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes+"'", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+func (r EventRecord) csvRow() []string {
+	venueID := ""
+	if r.VenueID != nil {
+		venueID = strconv.FormatUint(uint64(*r.VenueID), 10)
+	}
+	row := []string{
+		strconv.FormatUint(uint64(r.ID), 10), r.Title, r.Description, r.StartsAt, r.EndsAt, r.TimeZone,
+		r.SalesStart, r.SalesEnd, r.Location, venueID, strconv.FormatFloat(r.Price, 'f', -1, 64),
+		strconv.FormatInt(r.Capacity, 10), strconv.FormatInt(r.MaxTicketsPerUser, 10),
+		strconv.FormatBool(r.RefundsDisabled), strconv.Itoa(r.RefundCutoffDays),
+		strings.Join(r.Categories, csvListSeparator), r.Status,
+	}
+	for i := range row {
+		row[i] = escapeCSVCell(row[i])
+	}
+	return row
+}
+
+// sameAs reports whether r would leave the event exported as other as it
+// is. The status column is informational and never imported.
+func (r EventRecord) sameAs(other EventRecord) bool {
+	r.Status, other.Status = "", ""
+	r.Categories = append([]string{}, r.Categories...)
+	other.Categories = append([]string{}, other.Categories...)
+	sort.Strings(r.Categories)
+	sort.Strings(other.Categories)
+
+	a, b := r.csvRow(), other.csvRow()
+	for i := range a {
+		if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCSVRecord reads one CSV row into a record using the header's column
+// positions.
+func parseCSVRecord(columns map[string]int, row []string) (EventRecord, error) {
+	var record EventRecord
+	get := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return unescapeCSVCell(strings.TrimSpace(row[i]))
+		}
+		return ""
+	}
+
+	if v := get("id"); v != "" && v != "0" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return record, errors.New("Invalid id")
+		}
+		record.ID = uint(id)
+	}
+	if v := get("venue_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return record, errors.New("Invalid venue_id")
+		}
+		venueID := uint(id)
+		record.VenueID = &venueID
+	}
+
+	var err error
+	if v := get("price"); v != "" {
+		if record.Price, err = strconv.ParseFloat(v, 64); err != nil {
+			return record, errors.New("Invalid price")
+		}
+	}
+	if v := get("capacity"); v != "" {
+		if record.Capacity, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return record, errors.New("Invalid capacity")
+		}
+	}
+	if v := get("max_tickets_per_user"); v != "" {
+		if record.MaxTicketsPerUser, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return record, errors.New("Invalid max_tickets_per_user")
+		}
+	}
+	if v := get("refunds_disabled"); v != "" {
+		if record.RefundsDisabled, err = strconv.ParseBool(v); err != nil {
+			return record, errors.New("Invalid refunds_disabled, use true or false")
+		}
+	}
+	if v := get("refund_cutoff_days"); v != "" {
+		if record.RefundCutoffDays, err = strconv.Atoi(v); err != nil {
+			return record, errors.New("Invalid refund_cutoff_days")
+		}
+	}
+	if v := get("categories"); v != "" {
+		for _, slug := range strings.Split(v, csvListSeparator) {
+			if slug = strings.TrimSpace(slug); slug != "" {
+				record.Categories = append(record.Categories, slug)
+			}
+		}
+	}
+
+	record.Title = get("title")
+	record.Description = get("description")
+	record.StartsAt = get("starts_at")
+	record.EndsAt = get("ends_at")
+	record.TimeZone = get("time_zone")
+	record.SalesStart = get("sales_start")
+	record.SalesEnd = get("sales_end")
+	record.Location = get("location")
+	return record, nil
+}
+
+// readEventRecords parses an import file. Rows that can't be parsed at all
+// are reported in rowErrors and left out of the returned records, which are
+// keyed by their row number.
+func readEventRecords(r io.Reader, format string) (map[int]EventRecord, []importRowError, error) {
+	records := map[int]EventRecord{}
+	var rowErrors []importRowError
+
+	if format == "json" {
+		var list []EventRecord
+		if err := json.NewDecoder(r).Decode(&list); err != nil {
+			return nil, nil, errors.New("Invalid JSON, expected an array of events")
+		}
+		if len(list) > maxImportRows {
+			return nil, nil, fmt.Errorf("A file can contain at most %d events", maxImportRows)
+		}
+		for i, record := range list {
+			records[i+1] = record
+		}
+		return records, nil, nil
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.New("Invalid CSV, expected a header row")
+	}
+
+	known := map[string]bool{}
+	for _, column := range eventCSVColumns {
+		known[column] = true
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !known[name] {
+			return nil, nil, fmt.Errorf("Unknown CSV column %q", name)
+		}
+		columns[name] = i
+	}
+
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if row > maxImportRows {
+			return nil, nil, fmt.Errorf("A file can contain at most %d events", maxImportRows)
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: row, Error: err.Error()})
+			continue
+		}
+		record, err := parseCSVRecord(columns, fields)
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: row, Error: err.Error()})
+			continue
+		}
+		records[row] = record
+	}
+	return records, rowErrors, nil
+}
+
+// importEventRecord validates one record and creates or updates its event
+// inside the import transaction. It follows the same rules as CreateEvent
+// and UpdateEvent, except that categories are given by slug.
+func importEventRecord(tx *gorm.DB, record EventRecord, now time.Time) (importResult, bool, error) {
+	result := importResult{Action: "create"}
+
+	if strings.TrimSpace(record.Title) == "" {
+		return result, false, errors.New("title is required")
+	}
+	if record.Price < 0 || record.Capacity < 0 || record.MaxTicketsPerUser < 0 || record.RefundCutoffDays < 0 {
+		return result, false, errors.New("price, capacity, max_tickets_per_user and refund_cutoff_days must not be negative")
+	}
+
+	event := models.Event{Status: models.EventStatusDraft}
+	if record.ID != 0 {
+		result.Action = "update"
+		// Locked like in UpdateEvent, so a concurrent cancellation or status
+		// change is seen here and not overwritten.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Categories").First(&event, record.ID).Error; err != nil {
+			return result, false, fmt.Errorf("Event %d not found", record.ID)
+		}
+		// Rows left as they were exported are skipped, so an export can be
+		// edited and imported again even though it lists events that can
+		// no longer be changed.
+		if record.sameAs(newEventRecord(event)) {
+			result.Action = "unchanged"
+			result.ID = event.ID
+			return result, false, nil
+		}
+		if event.Closed() || (event.Status != models.EventStatusDraft && event.HasStarted(now)) {
+			return result, false, errors.New("Cannot update an event that has already started, finished or was cancelled")
+		}
+	}
+
+	req := EventRequest{
+		StartsAt:   record.StartsAt,
+		EndsAt:     record.EndsAt,
+		TimeZone:   record.TimeZone,
+		SalesStart: record.SalesStart,
+		SalesEnd:   record.SalesEnd,
+	}
+	startsAt, endsAt, timeZone, err := req.schedule(event)
+	if err != nil {
+		return result, false, err
+	}
+	if !startsAt.After(now) {
+		return result, false, errors.New("starts_at must be in the future")
+	}
+	salesStart, salesEnd, err := req.salesWindow(timeZone, startsAt)
+	if err != nil {
+		return result, false, err
+	}
+
+	var existing models.Event
+	if err := tx.Where("title = ? AND starts_at = ? AND id <> ?", record.Title, startsAt, event.ID).First(&existing).Error; err == nil {
+		return result, false, errors.New("An event with this title already starts at that time")
+	}
+
+	if record.VenueID != nil && venueChanged(event.VenueID, record.VenueID) {
+		var venue models.Venue
+		if err := tx.First(&venue, *record.VenueID).Error; err != nil {
+			return result, false, errors.New("Venue not found")
+		}
+		if event.ID != 0 {
+			var ticketsSold int64
+			tx.Model(&models.Ticket{}).Where("event_id = ?", event.ID).Count(&ticketsSold)
+			if ticketsSold > 0 {
+				return result, false, errors.New("Cannot change the venue of an event with sold tickets")
+			}
+		}
+	}
+
+	categories := []models.Category{}
+	if len(record.Categories) > 0 {
+		slugs := make([]string, len(record.Categories))
+		for i, slug := range record.Categories {
+			slugs[i] = slugify(slug)
+		}
+		tx.Where("slug IN ?", slugs).Find(&categories)
+		if len(categories) != len(slugs) {
+			return result, false, errors.New("Unknown category in categories")
+		}
+	}
+
+	if event.ID != 0 && record.Capacity < event.Capacity {
+		tierCapacity, err := eventTierCapacity(tx, event.ID)
+		if err != nil {
+			return result, false, err
+		}
+		if record.Capacity < tierCapacity {
+			return result, false, errTierCapacityExceeded
+		}
+	}
+
+	capacityRaised := event.ID != 0 && record.Capacity > event.Capacity
+	before := event
+
+	event.Title = record.Title
+	event.Description = record.Description
+	event.StartsAt = startsAt
+	event.EndsAt = endsAt
+	event.TimeZone = timeZone
+	event.SalesStart = salesStart
+	event.SalesEnd = salesEnd
+	event.Location = record.Location
+	if record.VenueID != nil {
+		event.VenueID = record.VenueID
+	}
+	event.Price = record.Price
+	event.Capacity = record.Capacity
+	event.MaxTicketsPerUser = record.MaxTicketsPerUser
+	event.RefundsDisabled = record.RefundsDisabled
+	event.RefundCutoffDays = record.RefundCutoffDays
+	if event.ID != 0 {
+		event.TrackScheduleChange(before)
+	}
+
+	// Status and publication only change through their own endpoints.
+	omit := []string{"Categories"}
+	if event.ID != 0 {
+		omit = append(omit, "Status", "PublishAt", "PublishedAt")
+	}
+	if err := tx.Omit(omit...).Save(&event).Error; err != nil {
+		return result, false, err
+	}
+	if err := tx.Model(&event).Association("Categories").Replace(categories); err != nil {
+		return result, false, err
+	}
+
+	result.ID = event.ID
+	return result, capacityRaised, nil
+}
+
+// importFormat works out whether an upload is CSV or JSON from the format
+// query parameter, the file name or the content type, in that order.
+func importFormat(c *gin.Context, filename string) string {
+	if format := strings.ToLower(c.Query("format")); format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	}
+	if strings.Contains(c.ContentType(), "json") {
+		return "json"
+	}
+	return "csv"
+}
+
+// ImportEvents creates and updates events from a CSV or JSON file, sent as
+// the "file" form field or as the raw request body. Every row is validated
+// and the import only commits when all rows are valid; with ?dry_run=true it
+// never commits and just reports what would happen.
+func ImportEvents(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+		var body io.Reader = c.Request.Body
+		filename := ""
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+			header, err := c.FormFile("file")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required in the \"file\" form field"})
+				return
+			}
+			if header.Size > maxImportSize {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File is larger than %d MB", maxImportSize>>20)})
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+				return
+			}
+			defer file.Close()
+			body, filename = file, header.Filename
+		}
+
+		format := importFormat(c, filename)
+		if format != "csv" && format != "json" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+			return
+		}
+
+		records, rowErrors, err := readEventRecords(io.LimitReader(body, maxImportSize), format)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		results := []importResult{}
+		var raisedCapacity []uint
+		rows := make([]int, 0, len(records))
+		for row := range records {
+			rows = append(rows, row)
+		}
+		sort.Ints(rows)
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				record := records[row]
+				result, capacityRaised, err := importEventRecord(tx, record, now)
+				if err != nil {
+					rowErrors = append(rowErrors, importRowError{Row: row, Error: err.Error()})
+					continue
+				}
+				result.Row = row
+				results = append(results, result)
+				if capacityRaised {
+					raisedCapacity = append(raisedCapacity, result.ID)
+				}
+			}
+			if len(rowErrors) > 0 || dryRun {
+				return errImportRolledBack
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errImportRolledBack) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import events"})
+			return
+		}
+
+		if len(rowErrors) > 0 {
+			sort.Slice(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Import contains invalid rows, nothing was imported",
+				"dry_run": dryRun,
+				"errors":  rowErrors,
+			})
+			return
+		}
+
+		// Ids of created rows only exist inside the rolled back transaction.
+		if dryRun {
+			for i := range results {
+				if results[i].Action == "create" {
+					results[i].ID = 0
+				}
+			}
+		} else {
+			for _, eventID := range raisedCapacity {
+				if err := models.OfferWaitlistSeats(db, eventID); err != nil {
+					log.Println("Failed to offer new capacity to waitlist:", err)
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run": dryRun,
+			"results": results,
+		})
+	}
+}
+
+// ExportEvents writes every event as CSV or JSON (?format=, default csv) in
+// the format ImportEvents reads.
+func ExportEvents(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := strings.ToLower(c.DefaultQuery("format", "csv"))
+		if format != "csv" && format != "json" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+			return
+		}
+
+		var events []models.Event
+		if err := db.Preload("Categories").Order("starts_at, id").Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+			return
+		}
+
+		records := make([]EventRecord, len(events))
+		for i, event := range events {
+			records[i] = newEventRecord(event)
+		}
+
+		filename := "events-" + time.Now().UTC().Format("20060102") + "." + format
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+		if format == "json" {
+			c.JSON(http.StatusOK, records)
+			return
+		}
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		writer := csv.NewWriter(c.Writer)
+		writer.Write(eventCSVColumns)
+		for _, record := range records {
+			writer.Write(record.csvRow())
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			log.Println("Failed to write event export:", err)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RecurrenceRequest struct {
+	Frequency       string   `json:"frequency" binding:"required,oneof=weekly monthly dates"`
+	Interval        int      `json:"interval" binding:"min=0,max=52"`
+	StartsAt        string   `json:"starts_at" binding:"required"` // start of the first occurrence, RFC 3339 or local time in time_zone
+	DurationMinutes int      `json:"duration_minutes" binding:"required,min=1"`
+	TimeZone        string   `json:"time_zone"` // IANA name, defaults to UTC
+	Until           string   `json:"until"`     // YYYY-MM-DD in time_zone, optional
+	Count           int      `json:"count" binding:"min=0,max=104"`
+	Dates           []string `json:"dates"`      // YYYY-MM-DD, for frequency "dates"
+	Exclusions      []string `json:"exclusions"` // YYYY-MM-DD
+}
+
+type SeriesRequest struct {
+	Title             string             `json:"title" binding:"required,max=200"`
+	Description       string             `json:"description"`
+	Location          string             `json:"location" binding:"required"`
+	VenueID           *uint              `json:"venue_id"`
+	Price             float64            `json:"price" binding:"min=0"`
+	Capacity          int64              `json:"capacity" binding:"min=0"`
+	MaxTicketsPerUser int64              `json:"max_tickets_per_user" binding:"min=0"`
+	CategoryIDs       []uint             `json:"category_ids"`
+	Recurrence        *RecurrenceRequest `json:"recurrence"` // required on create, optional on update
+}
+
+func (req RecurrenceRequest) apply(series *models.EventSeries) error {
+	timeZone := req.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return errors.New("Unknown time_zone, use an IANA name such as Asia/Jakarta")
+	}
+
+	startsAt, err := parseEventTime(req.StartsAt, loc)
+	if err != nil {
+		return errors.New("Invalid starts_at format, use RFC 3339")
+	}
+
+	var until *time.Time
+	if req.Until != "" {
+		day, err := time.ParseInLocation("2006-01-02", req.Until, loc)
+		if err != nil {
+			return errors.New("Invalid until format, use YYYY-MM-DD")
+		}
+		endOfDay := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		until = &endOfDay
+	}
+
+	for _, date := range append(append([]string{}, req.Dates...), req.Exclusions...) {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return errors.New("Invalid date " + date + ", use YYYY-MM-DD")
+		}
+	}
+	if req.Frequency == "dates" && len(req.Dates) == 0 {
+		return errors.New("dates are required for frequency \"dates\"")
+	}
+
+	series.Frequency = req.Frequency
+	series.Interval = req.Interval
+	if series.Interval == 0 {
+		series.Interval = 1
+	}
+	series.StartsAt = startsAt
+	series.DurationMinutes = req.DurationMinutes
+	series.TimeZone = timeZone
+	series.Until = until
+	series.Count = req.Count
+	series.Dates = uniqueDates(req.Dates)
+	series.Exclusions = uniqueDates(req.Exclusions)
+	return nil
+}
+
+// uniqueDates drops repeated days, which would otherwise produce two
+// occurrences with the same start time.
+func uniqueDates(dates []string) []string {
+	seen := make(map[string]bool, len(dates))
+	var unique []string
+	for _, date := range dates {
+		if !seen[date] {
+			seen[date] = true
+			unique = append(unique, date)
+		}
+	}
+	return unique
+}
+
+// occurrenceFor copies the series details onto one of its events. The
+// event's StartsAt must already be set.
+func occurrenceFor(series models.EventSeries, event *models.Event) {
+	event.SeriesID = &series.ID
+	event.Title = series.Title
+	event.Description = series.Description
+	event.Location = series.Location
+	event.VenueID = series.VenueID
+	event.Price = series.Price
+	event.Capacity = series.Capacity
+	event.MaxTicketsPerUser = series.MaxTicketsPerUser
+	event.EndsAt = event.StartsAt.Add(series.Duration())
+	event.TimeZone = series.TimeZone
+}
+
+var (
+	errOccurrenceInPast   = errors.New("Series occurrences must start in the future")
+	errOccurrenceConflict = errors.New("An event with this title already exists on one of the series dates")
+)
+
+// checkOccurrenceConflicts reports errOccurrenceConflict when an event other
+// than the ones in ignore already has title at one of dates, which would
+// break the unique title and start time index. Soft deleted events still
+// hold their place in the index.
+func checkOccurrenceConflicts(tx *gorm.DB, title string, dates []time.Time, ignore []uint) error {
+	if len(dates) == 0 {
+		return nil
+	}
+	query := tx.Unscoped().Model(&models.Event{}).Where("title = ? AND starts_at IN ?", title, dates)
+	if len(ignore) > 0 {
+		query = query.Where("id NOT IN ?", ignore)
+	}
+	var conflicts int64
+	if err := query.Count(&conflicts).Error; err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return errOccurrenceConflict
+	}
+	return nil
+}
+
+func venueChanged(before, after *uint) bool {
+	if before == nil || after == nil {
+		return before != after
+	}
+	return *before != *after
+}
+
+func GetSeries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var series models.EventSeries
+		if err := db.Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("starts_at") }).First(&series, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, series)
+	}
+}
+
+func CreateSeries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SeriesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Recurrence == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "recurrence is required"})
+			return
+		}
+
+		series := models.EventSeries{
+			Title:             req.Title,
+			Description:       req.Description,
+			Location:          req.Location,
+			VenueID:           req.VenueID,
+			Price:             req.Price,
+			Capacity:          req.Capacity,
+			MaxTicketsPerUser: req.MaxTicketsPerUser,
+		}
+		if err := req.Recurrence.apply(&series); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		dates := series.Occurrences()
+		if len(dates) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Recurrence rule does not produce any dates"})
+			return
+		}
+		if !dates[0].After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errOccurrenceInPast.Error()})
+			return
+		}
+
+		categories, ok := loadCategories(db, req.CategoryIDs)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown category in category_ids"})
+			return
+		}
+
+		if err := checkOccurrenceConflicts(db, series.Title, dates, nil); err != nil {
+			if errors.Is(err, errOccurrenceConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create series"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&series).Error; err != nil {
+				return err
+			}
+			for _, date := range dates {
+				event := models.Event{StartsAt: date, Status: models.EventStatusDraft, Categories: categories}
+				occurrenceFor(series, &event)
+				if err := tx.Create(&event).Error; err != nil {
+					return err
+				}
+				series.Events = append(series.Events, event)
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create series"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, series)
+	}
+}
+
+// UpdateSeries applies the new details to every future occurrence of the
+// series. Past, ongoing and finished occurrences keep their details. When a
+// new recurrence rule is given, future occurrences are regenerated: dates
+// that drop out of the rule are removed unless tickets were already sold.
+// Added dates start as drafts, like any new event, until they are published.
+// An occurrence keeps its venue when it has sales, and its capacity when the
+// new one is below its tickets sold or the capacity of its ticket types.
+// Single occurrences are edited through UpdateEvent.
+func UpdateSeries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var series models.EventSeries
+		if err := db.First(&series, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+			return
+		}
+
+		var req SeriesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		series.Title = req.Title
+		series.Description = req.Description
+		series.Location = req.Location
+		series.VenueID = req.VenueID
+		series.Price = req.Price
+		series.Capacity = req.Capacity
+		series.MaxTicketsPerUser = req.MaxTicketsPerUser
+		if req.Recurrence != nil {
+			if err := req.Recurrence.apply(&series); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		categories, ok := loadCategories(db, req.CategoryIDs)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown category in category_ids"})
+			return
+		}
+
+		now := time.Now()
+		var keptWithTickets []uint
+		var raisedCapacity []uint
+		var keptCapacity []uint
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Future occurrences are locked so that purchases and edits of
+			// single occurrences wait for the series update to finish.
+			var future []models.Event
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("series_id = ? AND starts_at > ?", series.ID, now).Find(&future).Error; err != nil {
+				return err
+			}
+
+			wanted := map[string]time.Time{}
+			if req.Recurrence != nil {
+				var existing []time.Time
+				if err := tx.Unscoped().Model(&models.Event{}).Where("series_id = ?", series.ID).Pluck("starts_at", &existing).Error; err != nil {
+					return err
+				}
+				scheduled := make(map[string]bool, len(existing))
+				for _, startsAt := range existing {
+					scheduled[startsAt.UTC().Format(time.RFC3339)] = true
+				}
+				for _, date := range series.Occurrences() {
+					key := date.UTC().Format(time.RFC3339)
+					if date.After(now) {
+						wanted[key] = date
+					} else if !scheduled[key] {
+						return errOccurrenceInPast
+					}
+				}
+			}
+
+			// Every occurrence that keeps or gets the series title must not
+			// collide with another event at the same start time.
+			var updated []uint
+			var dates []time.Time
+			for _, event := range future {
+				if event.Closed() {
+					continue
+				}
+				updated = append(updated, event.ID)
+				if req.Recurrence == nil {
+					dates = append(dates, event.StartsAt)
+				}
+			}
+			for _, date := range wanted {
+				dates = append(dates, date)
+			}
+			if err := checkOccurrenceConflicts(tx, series.Title, dates, updated); err != nil {
+				return err
+			}
+
+			if err := tx.Save(&series).Error; err != nil {
+				return err
+			}
+
+			for i := range future {
+				event := &future[i]
+				if event.Closed() {
+					continue
+				}
+
+				key := event.StartsAt.UTC().Format(time.RFC3339)
+				if req.Recurrence != nil {
+					if _, ok := wanted[key]; !ok {
+						var ticketsSold int64
+						tx.Model(&models.Ticket{}).Where("event_id = ?", event.ID).Count(&ticketsSold)
+						if ticketsSold > 0 {
+							keptWithTickets = append(keptWithTickets, event.ID)
+						} else if err := tx.Delete(event).Error; err != nil {
+							return err
+						}
+						continue
+					}
+					delete(wanted, key)
+				}
+
+				if series.Capacity > event.Capacity {
+					raisedCapacity = append(raisedCapacity, event.ID)
+				}
+				before := *event
+				occurrenceFor(series, event)
+				if venueChanged(before.VenueID, event.VenueID) {
+					var ticketsSold int64
+					tx.Model(&models.Ticket{}).Where("event_id = ?", event.ID).Count(&ticketsSold)
+					if ticketsSold > 0 {
+						event.VenueID = before.VenueID
+					}
+				}
+				if event.Capacity < before.Capacity {
+					var ticketsSold int64
+					if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("event_id = ?", event.ID).Count(&ticketsSold).Error; err != nil {
+						return err
+					}
+					tierCapacity, err := eventTierCapacity(tx, event.ID)
+					if err != nil {
+						return err
+					}
+					if event.Capacity < ticketsSold || event.Capacity < tierCapacity {
+						event.Capacity = before.Capacity
+						keptCapacity = append(keptCapacity, event.ID)
+					}
+				}
+				event.TrackScheduleChange(before)
+				if err := tx.Save(event).Error; err != nil {
+					return err
+				}
+				if req.CategoryIDs != nil {
+					if err := tx.Model(event).Association("Categories").Replace(categories); err != nil {
+						return err
+					}
+				}
+			}
+
+			for _, date := range wanted {
+				event := models.Event{StartsAt: date, Status: models.EventStatusDraft, Categories: categories}
+				occurrenceFor(series, &event)
+				if err := tx.Create(&event).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errOccurrenceInPast) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, errOccurrenceConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update series"})
+			return
+		}
+
+		for _, eventID := range raisedCapacity {
+			if err := models.OfferWaitlistSeats(db, eventID); err != nil {
+				log.Println("Failed to offer new capacity to waitlist:", err)
+			}
+		}
+
+		db.Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("starts_at") }).First(&series, series.ID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"series":                      series,
+			"kept_occurrences_with_sales": keptWithTickets,
+			"kept_capacity_occurrences":   keptCapacity,
+		})
+	}
+}
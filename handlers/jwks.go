@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"ticketink/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the public keys tokens are signed with, so other
+// services can verify ticketink tokens without sharing a secret.
+func GetJWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := utils.JWKS()
+		if err != nil {
+			log.Println("Failed to load JWT keys:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load keys"})
+			return
+		}
+
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": keys})
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"ticketink/models"
+	"ticketink/storage"
+	"ticketink/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxMediaSize     = 10 << 20 // bytes per uploaded file
+	maxMediaPixels   = 40_000_000
+	mediaPerEvent    = 20
+	thumbnailMaxSize = 320
+	thumbnailQuality = 85 // JPEG quality of thumbnails
+)
+
+// mediaExtensions lists the accepted upload types, detected from the file
+// contents rather than the client-supplied name or header.
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// withMediaURLs fills in the public URLs of the given media.
+func withMediaURLs(store storage.Storage, media []models.EventMedia) {
+	for i := range media {
+		media[i].URL = store.URL(media[i].StorageKey)
+		media[i].ThumbnailURL = store.URL(media[i].ThumbnailKey)
+	}
+}
+
+func orderedMedia(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+func randomMediaName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// encodeThumbnail writes thumbnails of JPEG uploads as JPEG and of everything
+// else as PNG, so transparency survives.
+func encodeThumbnail(w io.Writer, img image.Image, contentType string) (string, error) {
+	if contentType == "image/jpeg" {
+		return ".jpg", jpeg.Encode(w, img, &jpeg.Options{Quality: thumbnailQuality})
+	}
+	return ".png", png.Encode(w, img)
+}
+
+func decodeMedia(data []byte, contentType string) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Decode(r)
+	case "image/png":
+		return png.Decode(r)
+	default:
+		return gif.Decode(r)
+	}
+}
+
+// UploadEventMedia stores an image for the event's gallery from the "file"
+// form field, together with a thumbnail of at most thumbnailMaxSize pixels
+// on each side.
+func UploadEventMedia(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var event models.Event
+		if err := db.First(&event, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required in the \"file\" form field"})
+			return
+		}
+		if header.Size > maxMediaSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File is larger than %d MB", maxMediaSize>>20)})
+			return
+		}
+
+		var count int64
+		db.Model(&models.EventMedia{}).Where("event_id = ?", event.ID).Count(&count)
+		if count >= mediaPerEvent {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An event can have at most %d media files", mediaPerEvent)})
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, maxMediaSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		if len(data) > maxMediaSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File is larger than %d MB", maxMediaSize>>20)})
+			return
+		}
+
+		contentType := http.DetectContentType(data)
+		ext, ok := mediaExtensions[contentType]
+		if !ok {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only JPEG, PNG and GIF images are supported"})
+			return
+		}
+
+		// Check the dimensions before decoding so a small file can't claim
+		// an enormous canvas.
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File is not a valid image"})
+			return
+		}
+		if config.Width*config.Height > maxMediaPixels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Image dimensions are too large"})
+			return
+		}
+
+		img, err := decodeMedia(data, contentType)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File is not a valid image"})
+			return
+		}
+
+		var thumbnail bytes.Buffer
+		thumbExt, err := encodeThumbnail(&thumbnail, utils.Thumbnail(img, thumbnailMaxSize), contentType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate thumbnail"})
+			return
+		}
+
+		name, err := randomMediaName()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store media"})
+			return
+		}
+		media := models.EventMedia{
+			EventID:      event.ID,
+			Filename:     filepath.Base(header.Filename),
+			ContentType:  contentType,
+			Size:         int64(len(data)),
+			Width:        config.Width,
+			Height:       config.Height,
+			StorageKey:   fmt.Sprintf("events/%d/%s%s", event.ID, name, ext),
+			ThumbnailKey: fmt.Sprintf("events/%d/%s_thumb%s", event.ID, name, thumbExt),
+			Position:     int(count),
+		}
+
+		if err := store.Save(media.StorageKey, bytes.NewReader(data)); err != nil {
+			log.Println("Failed to store media:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store media"})
+			return
+		}
+		if err := store.Save(media.ThumbnailKey, &thumbnail); err != nil {
+			log.Println("Failed to store thumbnail:", err)
+			store.Delete(media.StorageKey)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store media"})
+			return
+		}
+
+		if err := db.Create(&media).Error; err != nil {
+			store.Delete(media.StorageKey)
+			store.Delete(media.ThumbnailKey)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save media"})
+			return
+		}
+
+		media.URL = store.URL(media.StorageKey)
+		media.ThumbnailURL = store.URL(media.ThumbnailKey)
+		c.JSON(http.StatusCreated, media)
+	}
+}
+
+func ListEventMedia(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		query := db
+		if c.GetString("role") != "admin" {
+			query = query.Scopes(models.Published)
+		}
+
+		var event models.Event
+		if err := query.First(&event, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		var media []models.EventMedia
+		orderedMedia(db).Where("event_id = ?", event.ID).Find(&media)
+		withMediaURLs(store, media)
+
+		c.JSON(http.StatusOK, gin.H{"media": media})
+	}
+}
+
+func DeleteEventMedia(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var media models.EventMedia
+		if err := db.Where("event_id = ?", c.Param("id")).First(&media, c.Param("mediaId")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+			return
+		}
+
+		if err := db.Delete(&media).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete media"})
+			return
+		}
+
+		// The record is gone either way; a file left behind is only wasted
+		// space.
+		for _, key := range []string{media.StorageKey, media.ThumbnailKey} {
+			if err := store.Delete(key); err != nil {
+				log.Println("Failed to delete media file", key+":", err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Media deleted successfully"})
+	}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errOrderNotFound    = &purchaseError{Status: http.StatusNotFound, Message: "Order not found"}
+	errOrderNotPending  = &purchaseError{Status: http.StatusBadRequest, Message: "Order is not awaiting confirmation"}
+	errOrderExpired     = &purchaseError{Status: http.StatusBadRequest, Message: "Order reservation has expired"}
+	errQuantityRequired = &purchaseError{Status: http.StatusBadRequest, Message: "Each item needs a quantity or seat_ids"}
+)
+
+type OrderItemRequest struct {
+	TicketTypeID *uint  `json:"ticket_type_id"`
+	Quantity     int64  `json:"quantity" binding:"min=0,max=20"`     // may be omitted when seat_ids are given
+	SeatIDs      []uint `json:"seat_ids" binding:"omitempty,max=20"` // required for events with reserved seating
+}
+
+type OrderRequest struct {
+	EventID   uint               `json:"event_id" binding:"required"`
+	Items     []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+	Hold      bool               `json:"hold"` // reserve now, pay later via /orders/:id/confirm
+	PromoCode string             `json:"promo_code"`
+}
+
+func GetOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		offset := (page - 1) * limit
+
+		query := db.Model(&models.Order{}).Preload("Event").Preload("Tickets")
+		if user.Role != "admin" {
+			query = query.Where("user_id = ?", user.ID)
+		} else if userID := c.Query("user_id"); userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+
+		var orders []models.Order
+		var totalItems int64
+		query.Count(&totalItems).Order("id DESC").Limit(limit).Offset(offset).Find(&orders)
+
+		totalPages := int((totalItems + int64(limit) - 1) / int64(limit))
+
+		c.JSON(http.StatusOK, gin.H{
+			"orders": orders,
+			"pagination": gin.H{
+				"current_page": page,
+				"total_pages":  totalPages,
+				"total_items":  totalItems,
+			},
+		})
+	}
+}
+
+func GetOrderByID(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var order models.Order
+		if err := db.Preload("Event").Preload("Tickets.TicketType").First(&order, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if order.UserID != user.ID && user.Role != "admin" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+func CreateOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req OrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var order models.Order
+		err = db.Transaction(func(tx *gorm.DB) error {
+			order = models.Order{
+				UserID:  user.ID,
+				EventID: req.EventID,
+				Status:  "completed",
+			}
+			if req.Hold {
+				deadline := time.Now().Add(holdDuration)
+				order.Status = "pending"
+				order.ExpiresAt = &deadline
+			}
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+
+			for _, item := range req.Items {
+				if item.Quantity == 0 && len(item.SeatIDs) == 0 {
+					return errQuantityRequired
+				}
+				tickets, err := reserveTickets(tx, ticketRequest{
+					UserID:       user.ID,
+					EventID:      req.EventID,
+					TicketTypeID: item.TicketTypeID,
+					Quantity:     item.Quantity,
+					OrderID:      &order.ID,
+					Hold:         req.Hold,
+					PromoCode:    req.PromoCode,
+					SeatIDs:      item.SeatIDs,
+				})
+				if err != nil {
+					return err
+				}
+				for _, ticket := range tickets {
+					order.Total += ticket.Price
+				}
+				order.Tickets = append(order.Tickets, tickets...)
+			}
+
+			return tx.Model(&order).Update("total", order.Total).Error
+		})
+		if err != nil {
+			respondPurchaseError(c, err, "Failed to place order")
+			return
+		}
+
+		message := "Order placed successfully"
+		if req.Hold {
+			message = "Tickets reserved, confirm the order before it expires"
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": message,
+			"order":   order,
+		})
+	}
+}
+
+func ConfirmOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var order models.Order
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil || order.UserID != user.ID {
+				return errOrderNotFound
+			}
+			if order.Status != "pending" {
+				return errOrderNotPending
+			}
+			if order.ExpiresAt != nil && order.ExpiresAt.Before(time.Now()) {
+				return errOrderExpired
+			}
+
+			if err := tx.Model(&models.Ticket{}).
+				Where("order_id = ? AND status = ?", order.ID, "reserved").
+				Updates(map[string]interface{}{"status": "purchased", "expires_at": nil, "paid_at": time.Now()}).Error; err != nil {
+				return err
+			}
+
+			order.Status = "completed"
+			order.ExpiresAt = nil
+			return tx.Save(&order).Error
+		})
+		if err != nil {
+			respondPurchaseError(c, err, "Failed to confirm order")
+			return
+		}
+
+		db.Preload("Tickets").First(&order, order.ID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Order confirmed successfully",
+			"order":   order,
+		})
+	}
+}
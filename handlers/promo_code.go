@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errPromoInvalid       = &purchaseError{Status: http.StatusBadRequest, Message: "Invalid promo code"}
+	errPromoNotApplicable = &purchaseError{Status: http.StatusBadRequest, Message: "Promo code does not apply to this event"}
+	errPromoNotValidNow   = &purchaseError{Status: http.StatusBadRequest, Message: "Promo code is not valid at this time"}
+	errPromoExhausted     = &purchaseError{Status: http.StatusBadRequest, Message: "Promo code usage limit reached"}
+	errPromoUserLimit     = &purchaseError{Status: http.StatusBadRequest, Message: "You have already used this promo code the maximum number of times"}
+)
+
+type PromoCodeRequest struct {
+	Code         string  `json:"code" binding:"required,max=50"`
+	DiscountType string  `json:"discount_type" binding:"required,oneof=percentage fixed"`
+	Value        float64 `json:"value" binding:"required,gt=0"`
+	MaxUses      int64   `json:"max_uses" binding:"min=0"`
+	PerUserLimit int64   `json:"per_user_limit" binding:"min=0"`
+	ValidFrom    string  `json:"valid_from"`  // RFC 3339, optional
+	ValidUntil   string  `json:"valid_until"` // RFC 3339, optional
+	EventID      *uint   `json:"event_id"`
+	Active       *bool   `json:"active"`
+}
+
+func (req PromoCodeRequest) apply(promo *models.PromoCode) error {
+	if req.DiscountType == "percentage" && req.Value > 100 {
+		return errors.New("Percentage discount cannot exceed 100")
+	}
+	validFrom, err := parseOptionalTime(req.ValidFrom)
+	if err != nil {
+		return errors.New("Invalid valid_from format, use RFC 3339")
+	}
+	validUntil, err := parseOptionalTime(req.ValidUntil)
+	if err != nil {
+		return errors.New("Invalid valid_until format, use RFC 3339")
+	}
+	if validFrom != nil && validUntil != nil && !validUntil.After(*validFrom) {
+		return errors.New("valid_until must be after valid_from")
+	}
+
+	promo.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	promo.DiscountType = req.DiscountType
+	promo.Value = req.Value
+	promo.MaxUses = req.MaxUses
+	promo.PerUserLimit = req.PerUserLimit
+	promo.ValidFrom = validFrom
+	promo.ValidUntil = validUntil
+	promo.EventID = req.EventID
+	promo.Active = req.Active == nil || *req.Active
+	return nil
+}
+
+// redeemPromoCode validates code for quantity tickets of the event and must be
+// called inside a transaction. The promo code row is locked so concurrent
+// checkouts cannot push it past its usage limits.
+func redeemPromoCode(tx *gorm.DB, code string, eventID, userID uint, quantity int64) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ? AND active = ?", strings.ToUpper(strings.TrimSpace(code)), true).First(&promo).Error; err != nil {
+		return nil, errPromoInvalid
+	}
+
+	if promo.EventID != nil && *promo.EventID != eventID {
+		return nil, errPromoNotApplicable
+	}
+
+	now := time.Now()
+	if (promo.ValidFrom != nil && now.Before(*promo.ValidFrom)) || (promo.ValidUntil != nil && now.After(*promo.ValidUntil)) {
+		return nil, errPromoNotValidNow
+	}
+
+	if promo.MaxUses > 0 {
+		var uses int64
+		if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("promo_code_id = ?", promo.ID).Count(&uses).Error; err != nil {
+			return nil, errAvailability
+		}
+		if uses+quantity > promo.MaxUses {
+			return nil, errPromoExhausted
+		}
+	}
+
+	if promo.PerUserLimit > 0 {
+		var uses int64
+		if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("promo_code_id = ? AND user_id = ?", promo.ID, userID).Count(&uses).Error; err != nil {
+			return nil, errAvailability
+		}
+		if uses+quantity > promo.PerUserLimit {
+			return nil, errPromoUserLimit
+		}
+	}
+
+	return &promo, nil
+}
+
+func ListPromoCodes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Model(&models.PromoCode{})
+		if eventID := c.Query("event_id"); eventID != "" {
+			query = query.Where("event_id = ?", eventID)
+		}
+
+		var promoCodes []models.PromoCode
+		if err := query.Order("id DESC").Find(&promoCodes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promo codes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"promo_codes": promoCodes})
+	}
+}
+
+func CreatePromoCode(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PromoCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var promo models.PromoCode
+		if err := req.apply(&promo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var existing models.PromoCode
+		if err := db.Where("code = ?", promo.Code).First(&existing).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Promo code must be unique"})
+			return
+		}
+
+		if err := db.Create(&promo).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promo code"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, promo)
+	}
+}
+
+func UpdatePromoCode(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var promo models.PromoCode
+		if err := db.First(&promo, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+			return
+		}
+
+		var req PromoCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := req.apply(&promo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var existing models.PromoCode
+		if err := db.Where("code = ? AND id <> ?", promo.Code, promo.ID).First(&existing).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Promo code must be unique"})
+			return
+		}
+
+		if err := db.Save(&promo).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update promo code"})
+			return
+		}
+
+		c.JSON(http.StatusOK, promo)
+	}
+}
+
+func DeletePromoCode(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var promo models.PromoCode
+		if err := db.First(&promo, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+			return
+		}
+
+		if err := db.Delete(&promo).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete promo code"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Promo code deleted successfully"})
+	}
+}
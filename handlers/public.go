@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"ticketink/models"
+	"ticketink/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// The public catalog is served without authentication, so it uses its own
+// response types that leave out internal fields such as refund policy,
+// soft-delete state and storage keys.
+
+type PublicCategory struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type PublicMedia struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+type PublicTicketType struct {
+	ID                uint    `json:"id"`
+	Name              string  `json:"name"`
+	Price             float64 `json:"price"`
+	RemainingCapacity int64   `json:"remaining_capacity"`
+	SoldOut           bool    `json:"sold_out"`
+	MaxPerUser        int64   `json:"max_per_user,omitempty"`
+}
+
+type PublicEvent struct {
+	ID                  uint               `json:"id"`
+	Title               string             `json:"title"`
+	Description         string             `json:"description"`
+	StartsAt            time.Time          `json:"starts_at"`
+	EndsAt              time.Time          `json:"ends_at"`
+	TimeZone            string             `json:"time_zone"`
+	Location            string             `json:"location"`
+	Venue               string             `json:"venue,omitempty"`
+	Price               float64            `json:"price"`
+	Status              string             `json:"status"`
+	SalesStatus         string             `json:"sales_status"`
+	SalesOpensInSeconds *int64             `json:"sales_opens_in_seconds,omitempty"`
+	RemainingCapacity   int64              `json:"remaining_capacity"`
+	SoldOut             bool               `json:"sold_out"`
+	MaxTicketsPerUser   int64              `json:"max_tickets_per_user,omitempty"`
+	Categories          []PublicCategory   `json:"categories"`
+	Media               []PublicMedia      `json:"media"`
+	TicketTypes         []PublicTicketType `json:"ticket_types,omitempty"` // only in the event detail
+}
+
+// takenCapacity returns, per event, how many places are held by purchased
+// or reserved tickets or set aside for waitlist offers.
+func takenCapacity(db *gorm.DB, eventIDs []uint) map[uint]int64 {
+	taken := map[uint]int64{}
+	if len(eventIDs) == 0 {
+		return taken
+	}
+
+	var counts []struct {
+		EventID uint
+		Count   int64
+	}
+	db.Model(&models.Ticket{}).Scopes(models.HoldsInventory).
+		Select("event_id, COUNT(*) AS count").Where("event_id IN ?", eventIDs).Group("event_id").Scan(&counts)
+	for _, count := range counts {
+		taken[count.EventID] += count.Count
+	}
+
+	counts = nil
+	db.Model(&models.WaitlistEntry{}).Scopes(models.ActiveOffers).
+		Select("event_id, COUNT(*) AS count").Where("event_id IN ?", eventIDs).Group("event_id").Scan(&counts)
+	for _, count := range counts {
+		taken[count.EventID] += count.Count
+	}
+	return taken
+}
+
+func newPublicEvent(event models.Event, taken int64, store storage.Storage, now time.Time) PublicEvent {
+	listing := newEventListing(event, now)
+	public := PublicEvent{
+		ID:                  event.ID,
+		Title:               event.Title,
+		Description:         event.Description,
+		StartsAt:            event.StartsAt.In(event.Zone()),
+		EndsAt:              event.EndsAt.In(event.Zone()),
+		TimeZone:            event.TimeZone,
+		Location:            event.Location,
+		Price:               event.Price,
+		Status:              event.Status,
+		SalesStatus:         listing.SalesStatus,
+		SalesOpensInSeconds: listing.SalesOpensInSeconds,
+		RemainingCapacity:   max(event.Capacity-taken, 0),
+		MaxTicketsPerUser:   event.MaxTicketsPerUser,
+		Categories:          []PublicCategory{},
+		Media:               []PublicMedia{},
+	}
+	public.SoldOut = public.RemainingCapacity == 0
+	if event.Venue != nil {
+		public.Venue = event.Venue.Name
+	}
+	for _, category := range event.Categories {
+		public.Categories = append(public.Categories, PublicCategory{Name: category.Name, Slug: category.Slug})
+	}
+	for _, media := range event.Media {
+		public.Media = append(public.Media, PublicMedia{
+			URL:          store.URL(media.StorageKey),
+			ThumbnailURL: store.URL(media.ThumbnailKey),
+			Width:        media.Width,
+			Height:       media.Height,
+		})
+	}
+	return public
+}
+
+// ListPublicEvents is the anonymous event catalog. It takes the same query
+// parameters as ListEvents except group_by, and leaves out finished and
+// cancelled events unless they are asked for with ?status=.
+func ListPublicEvents(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params := EventListQuery{Page: 1, Limit: 10, Sort: "date"}
+		if err := c.ShouldBindQuery(&params); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page, limit := params.Page, params.Limit
+
+		query, err := filterEvents(db, params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = query.Scopes(models.Published)
+		if params.Status == "" {
+			query = query.Where("events.status NOT IN ?", []string{models.EventStatusFinished, models.EventStatusCancelled})
+		}
+		if params.Category != "" {
+			query = query.Where("events.id IN (?)", categoryFilter(db, params.Category))
+		}
+		query = query.Session(&gorm.Session{})
+
+		var totalItems int64
+		query.Count(&totalItems)
+
+		var events []models.Event
+		sortedEvents(db, query, params).Preload("Categories").Preload("Venue").Preload("Media", orderedMedia).
+			Limit(limit).Offset((page - 1) * limit).Find(&events)
+
+		ids := make([]uint, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		taken := takenCapacity(db, ids)
+
+		now := time.Now()
+		publicEvents := make([]PublicEvent, len(events))
+		for i, event := range events {
+			publicEvents[i] = newPublicEvent(event, taken[event.ID], store, now)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"events": publicEvents,
+			"sort":   params.Sort,
+			"pagination": gin.H{
+				"current_page": page,
+				"total_pages":  (int(totalItems) + limit - 1) / limit,
+				"total_items":  totalItems,
+			},
+		})
+	}
+}
+
+func GetPublicEvent(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var event models.Event
+		err := db.Scopes(models.Published).Preload("Categories").Preload("Venue").Preload("Media", orderedMedia).Preload("TicketTypes").First(&event, id).Error
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		public := newPublicEvent(event, takenCapacity(db, []uint{event.ID})[event.ID], store, time.Now())
+
+		for _, ticketType := range event.TicketTypes {
+			var tierTaken int64
+			db.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("ticket_type_id = ?", ticketType.ID).Count(&tierTaken)
+
+			// A tier can't have more left than the event as a whole.
+			remaining := min(max(ticketType.Capacity-tierTaken, 0), public.RemainingCapacity)
+			public.TicketTypes = append(public.TicketTypes, PublicTicketType{
+				ID:                ticketType.ID,
+				Name:              ticketType.Name,
+				Price:             ticketType.Price,
+				RemainingCapacity: remaining,
+				SoldOut:           remaining == 0,
+				MaxPerUser:        ticketType.MaxPerUser,
+			})
+		}
+
+		c.JSON(http.StatusOK, public)
+	}
+}
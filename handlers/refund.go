@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func listRefunds(c *gin.Context, query *gorm.DB) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset := (page - 1) * limit
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if eventID := c.Query("event_id"); eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
+
+	var refunds []models.Refund
+	var totalItems int64
+	query.Count(&totalItems).Order("id DESC").Limit(limit).Offset(offset).Find(&refunds)
+
+	totalPages := int((totalItems + int64(limit) - 1) / int64(limit))
+
+	c.JSON(http.StatusOK, gin.H{
+		"refunds": refunds,
+		"pagination": gin.H{
+			"current_page": page,
+			"total_pages":  totalPages,
+			"total_items":  totalItems,
+		},
+	})
+}
+
+func GetMyRefunds(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		listRefunds(c, db.Model(&models.Refund{}).Preload("Ticket").Where("user_id = ?", user.ID))
+	}
+}
+
+func ListRefunds(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listRefunds(c, db.Model(&models.Refund{}).Preload("Ticket"))
+	}
+}
+
+// transitionRefund moves a refund from one of the allowed states to next.
+func transitionRefund(db *gorm.DB, c *gin.Context, from []string, next string) {
+	id := c.Param("id")
+
+	var refund models.Refund
+	if err := db.First(&refund, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Refund not found"})
+		return
+	}
+
+	allowed := false
+	for _, status := range from {
+		if refund.Status == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refund cannot be " + next + " while " + refund.Status})
+		return
+	}
+
+	now := time.Now()
+	switch next {
+	case "approved":
+		refund.ApprovedAt = &now
+		refund.ReviewedBy = c.GetString("email")
+	case "rejected":
+		refund.ReviewedBy = c.GetString("email")
+	case "processed":
+		refund.ProcessedAt = &now
+	}
+	refund.Status = next
+
+	if err := db.Save(&refund).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update refund"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Refund " + next + " successfully", "refund": refund})
+}
+
+func ApproveRefund(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transitionRefund(db, c, []string{"requested"}, "approved")
+	}
+}
+
+func RejectRefund(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transitionRefund(db, c, []string{"requested"}, "rejected")
+	}
+}
+
+func ProcessRefund(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transitionRefund(db, c, []string{"approved"}, "processed")
+	}
+}
@@ -27,11 +27,33 @@ func GetEventReport(db *gorm.DB) gin.HandlerFunc {
 			Row().
 			Scan(&totalRevenue)
 
+		var totalDiscount float64
+		db.Model(&models.Ticket{}).
+			Where("event_id = ? AND status = ?", id, "purchased").
+			Select("COALESCE(SUM(discount), 0)").
+			Row().
+			Scan(&totalDiscount)
+
+		revenue := refundAdjustedRevenue(
+			db.Model(&models.Ticket{}).Where("event_id = ?", event.ID),
+			db.Model(&models.Refund{}).Where("event_id = ?", event.ID),
+		)
+
+		tiers := tierBreakdown(db.Where("tickets.event_id = ?", event.ID))
+		promoCodes := promoCodeBreakdown(db.Where("tickets.event_id = ?", event.ID))
+
 		report := models.Report{
 			EventID:          event.ID,
 			EventTitle:       event.Title,
 			TicketsSold:      int64(ticketsSold),
 			RevenueGenerated: totalRevenue,
+			TotalDiscount:    totalDiscount,
+			GrossRevenue:     revenue.Gross,
+			RefundedAmount:   revenue.Refunded,
+			PendingRefunds:   revenue.PendingRefunds,
+			NetRevenue:       revenue.Net,
+			Tiers:            tiers,
+			PromoCodes:       promoCodes,
 		}
 
 		c.JSON(http.StatusOK, report)
@@ -57,9 +79,89 @@ func GetRevenueSummary(db *gorm.DB) gin.HandlerFunc {
 			Row().
 			Scan(&totalRevenue)
 
+		breakdownQuery := db
+		if startDate != "" && endDate != "" {
+			breakdownQuery = breakdownQuery.Where("tickets.created_at BETWEEN ? AND ?", startDate, endDate).Session(&gorm.Session{})
+		}
+
+		var totalDiscount float64
+		breakdownQuery.Model(&models.Ticket{}).
+			Where("tickets.status = ?", "purchased").
+			Select("COALESCE(SUM(tickets.discount), 0)").
+			Row().
+			Scan(&totalDiscount)
+
+		paidQuery := db.Model(&models.Ticket{})
+		refundQuery := db.Model(&models.Refund{})
+		if startDate != "" && endDate != "" {
+			paidQuery = paidQuery.Where("paid_at BETWEEN ? AND ?", startDate, endDate)
+			refundQuery = refundQuery.Where("created_at BETWEEN ? AND ?", startDate, endDate)
+		}
+		revenue := refundAdjustedRevenue(paidQuery, refundQuery)
+
 		c.JSON(http.StatusOK, gin.H{
 			"total_tickets_sold": totalTicketsSold,
 			"total_revenue":      totalRevenue,
+			"total_discount":     totalDiscount,
+			"gross_revenue":      revenue.Gross,
+			"refunded_amount":    revenue.Refunded,
+			"pending_refunds":    revenue.PendingRefunds,
+			"net_revenue":        revenue.Net,
+			"tiers":              tierBreakdown(breakdownQuery),
+			"promo_codes":        promoCodeBreakdown(breakdownQuery),
 		})
 	}
 }
+
+// tierBreakdown groups purchased tickets by ticket type. Tickets bought before
+// an event had tiers are reported with a nil ticket_type_id.
+func tierBreakdown(query *gorm.DB) []models.TierReport {
+	tiers := []models.TierReport{}
+	query.Model(&models.Ticket{}).
+		Select("tickets.event_id, tickets.ticket_type_id, COALESCE(ticket_types.name, '') AS name, COUNT(*) AS tickets_sold, SUM(tickets.price) AS revenue, SUM(tickets.discount) AS discount").
+		Joins("LEFT JOIN ticket_types ON ticket_types.id = tickets.ticket_type_id").
+		Where("tickets.status = ?", "purchased").
+		Group("tickets.event_id, tickets.ticket_type_id, ticket_types.name").
+		Scan(&tiers)
+	return tiers
+}
+
+// promoCodeBreakdown groups purchased tickets by the promo code applied to them.
+func promoCodeBreakdown(query *gorm.DB) []models.PromoCodeReport {
+	promoCodes := []models.PromoCodeReport{}
+	query.Model(&models.Ticket{}).
+		Select("tickets.promo_code_id, promo_codes.code, COUNT(*) AS uses, SUM(tickets.discount) AS discount, SUM(tickets.price) AS revenue").
+		Joins("JOIN promo_codes ON promo_codes.id = tickets.promo_code_id").
+		Where("tickets.status = ?", "purchased").
+		Group("tickets.promo_code_id, promo_codes.code").
+		Scan(&promoCodes)
+	return promoCodes
+}
+
+type revenueTotals struct {
+	Gross          float64
+	Refunded       float64
+	PendingRefunds float64
+	Net            float64
+}
+
+// refundAdjustedRevenue totals everything ever paid for the tickets matched by
+// ticketQuery, including tickets cancelled later, and subtracts the refunds
+// matched by refundQuery that have actually been paid back.
+func refundAdjustedRevenue(ticketQuery, refundQuery *gorm.DB) revenueTotals {
+	var totals revenueTotals
+	ticketQuery.Where("paid_at IS NOT NULL").
+		Select("COALESCE(SUM(price), 0)").
+		Row().
+		Scan(&totals.Gross)
+	refundQuery.Session(&gorm.Session{}).Where("status = ?", "processed").
+		Select("COALESCE(SUM(amount), 0)").
+		Row().
+		Scan(&totals.Refunded)
+	refundQuery.Session(&gorm.Session{}).Where("status IN ?", []string{"requested", "approved"}).
+		Select("COALESCE(SUM(amount), 0)").
+		Row().
+		Scan(&totals.PendingRefunds)
+	totals.Net = totals.Gross - totals.Refunded
+	return totals
+}
@@ -1,16 +1,321 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"ticketink/models"
+	"ticketink/utils"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"github.com/skip2/go-qrcode"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// purchaseError is returned from inside a purchase transaction and carries
+// the HTTP response the handler should send when the transaction fails. Code
+// is an optional machine-readable reason for clients to switch on, and
+// Remaining tells buyers who hit a purchase limit how many they can still buy.
+type purchaseError struct {
+	Status    int
+	Message   string
+	Code      string
+	Remaining *int64
+}
+
+func (e *purchaseError) Error() string {
+	return e.Message
+}
+
+var (
+	errEventNotFound        = &purchaseError{Status: http.StatusNotFound, Message: "Event not found"}
+	errEventFinished        = &purchaseError{Status: http.StatusBadRequest, Message: "Cannot purchase a ticket for event that has already finished"}
+	errEventCancelled       = &purchaseError{Status: http.StatusBadRequest, Message: "Event has been cancelled", Code: "event_cancelled"}
+	errSoldOut              = &purchaseError{Status: http.StatusBadRequest, Message: "Event is sold out"}
+	errAvailability         = &purchaseError{Status: http.StatusInternalServerError, Message: "Failed to check ticket availability"}
+	errTicketTypeRequired   = &purchaseError{Status: http.StatusBadRequest, Message: "ticket_type_id is required for this event"}
+	errTicketTypeNotFound   = &purchaseError{Status: http.StatusNotFound, Message: "Ticket type not found"}
+	errNotYetOnSale         = &purchaseError{Status: http.StatusBadRequest, Message: "Tickets are not on sale yet", Code: "not_yet_on_sale"}
+	errSalesClosed          = &purchaseError{Status: http.StatusBadRequest, Message: "Ticket sales have closed", Code: "sales_closed"}
+	errTierNotOnSale        = &purchaseError{Status: http.StatusBadRequest, Message: "Ticket type is not on sale yet", Code: "not_yet_on_sale"}
+	errTierSalesClosed      = &purchaseError{Status: http.StatusBadRequest, Message: "Ticket type sales have closed", Code: "sales_closed"}
+	errTierSoldOut          = &purchaseError{Status: http.StatusBadRequest, Message: "Ticket type is sold out"}
+	errTicketNotFound       = &purchaseError{Status: http.StatusNotFound, Message: "Ticket not found"}
+	errTicketCancelled      = &purchaseError{Status: http.StatusBadRequest, Message: "Cancelled tickets cannot be changed, buy a new ticket instead"}
+	errTicketEventStarted   = &purchaseError{Status: http.StatusBadRequest, Message: "Cannot update tickets for events that have already started"}
+	errTicketEventCancelled = &purchaseError{Status: http.StatusBadRequest, Message: "Cannot update tickets for a cancelled event"}
+	errReservationExpired   = &purchaseError{Status: http.StatusBadRequest, Message: "Reservation has expired"}
+	errConfirmThroughOrder  = &purchaseError{Status: http.StatusBadRequest, Message: "This ticket is part of an order, confirm it with POST /api/orders/:id/confirm"}
+	errRefundExists         = &purchaseError{Status: http.StatusConflict, Message: "A refund already exists for this ticket"}
+	errBusy                 = &purchaseError{Status: http.StatusConflict, Message: "Too many purchases for this event right now, please try again", Code: "busy"}
+	errSeatsRequired        = &purchaseError{Status: http.StatusBadRequest, Message: "This event has reserved seating, choose one seat per ticket"}
+	errSeatsNotAllowed      = &purchaseError{Status: http.StatusBadRequest, Message: "Event does not have reserved seating"}
+	errSeatNotFound         = &purchaseError{Status: http.StatusBadRequest, Message: "Seat does not exist at this venue"}
+	errSeatTaken            = &purchaseError{Status: http.StatusConflict, Message: "One or more of the selected seats are no longer available"}
+)
+
+// lockError turns lock wait timeouts and deadlocks, which happen when many
+// buyers queue up for the same event, into a retryable conflict. Any other
+// error is returned unchanged and ends up as a 500.
+func lockError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && (mysqlErr.Number == 1205 || mysqlErr.Number == 1213) {
+		return errBusy
+	}
+	return err
+}
+
+func respondPurchaseError(c *gin.Context, err error, fallback string) {
+	var perr *purchaseError
+	if errors.As(err, &perr) {
+		response := gin.H{"error": perr.Message}
+		if perr.Code != "" {
+			response["code"] = perr.Code
+		}
+		if perr.Remaining != nil {
+			response["remaining"] = *perr.Remaining
+		}
+		c.JSON(perr.Status, response)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
+
+// purchaseLimitError reports that buying quantity more tickets would take
+// the buyer past a per-user limit.
+func purchaseLimitError(scope string, limit, held int64) *purchaseError {
+	remaining := max(limit-held, 0)
+	message := fmt.Sprintf("You can buy at most %d tickets for this %s, you can buy %d more", limit, scope, remaining)
+	if remaining == 0 {
+		message = fmt.Sprintf("You can buy at most %d tickets for this %s and have reached that limit", limit, scope)
+	}
+	return &purchaseError{Status: http.StatusBadRequest, Message: message, Code: "purchase_limit_exceeded", Remaining: &remaining}
+}
+
+// holdDuration is how long a "reserved" ticket keeps its seat while the buyer
+// completes payment.
+const holdDuration = 15 * time.Minute
+
+// ticketRequest describes a batch of identical tickets to reserve for a user.
+// With Hold set the tickets are created as time-limited reservations instead
+// of completed purchases. Events with reserved seating need one seat per
+// ticket, and Quantity may be left at zero in that case.
+type ticketRequest struct {
+	UserID       uint
+	EventID      uint
+	TicketTypeID *uint
+	Quantity     int64
+	OrderID      *uint
+	Hold         bool
+	PromoCode    string
+	SeatIDs      []uint
+}
+
+// reserveTickets creates the requested tickets and must be called inside a
+// transaction. The event row is locked first so concurrent purchases for the
+// same event are serialized and the capacity checks cannot be raced.
+func reserveTickets(tx *gorm.DB, req ticketRequest) ([]models.Ticket, error) {
+	ticketTypeID, quantity := req.TicketTypeID, req.Quantity
+	if quantity == 0 {
+		quantity = int64(len(req.SeatIDs))
+	}
+
+	var event models.Event
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(models.Published).First(&event, req.EventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errEventNotFound
+		}
+		return nil, lockError(err)
+	}
+
+	now := time.Now()
+	if event.Status == models.EventStatusCancelled {
+		return nil, errEventCancelled
+	}
+	if event.Status == models.EventStatusFinished || event.HasStarted(now) {
+		return nil, errEventFinished
+	}
+	switch event.SalesStatus(now) {
+	case models.SalesNotYetOpen:
+		return nil, errNotYetOnSale
+	case models.SalesClosed:
+		return nil, errSalesClosed
+	}
+
+	price := event.Price
+	if ticketTypeID != nil {
+		var ticketType models.TicketType
+		if err := tx.Where("id = ? AND event_id = ?", *ticketTypeID, event.ID).First(&ticketType).Error; err != nil {
+			return nil, errTicketTypeNotFound
+		}
+		if ticketType.SalesStart != nil && now.Before(*ticketType.SalesStart) {
+			return nil, errTierNotOnSale
+		}
+		if ticketType.SalesEnd != nil && now.After(*ticketType.SalesEnd) {
+			return nil, errTierSalesClosed
+		}
+
+		var tierSold int64
+		if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("ticket_type_id = ?", ticketType.ID).Count(&tierSold).Error; err != nil {
+			return nil, errAvailability
+		}
+		if tierSold+quantity > ticketType.Capacity {
+			return nil, errTierSoldOut
+		}
+		if ticketType.MaxPerUser > 0 {
+			var held int64
+			if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("ticket_type_id = ? AND user_id = ?", ticketType.ID, req.UserID).Count(&held).Error; err != nil {
+				return nil, errAvailability
+			}
+			if held+quantity > ticketType.MaxPerUser {
+				return nil, purchaseLimitError("ticket type", ticketType.MaxPerUser, held)
+			}
+		}
+		price = ticketType.Price
+	} else {
+		var tierCount int64
+		if err := tx.Model(&models.TicketType{}).Where("event_id = ?", event.ID).Count(&tierCount).Error; err != nil {
+			return nil, errAvailability
+		}
+		if tierCount > 0 {
+			return nil, errTicketTypeRequired
+		}
+	}
+
+	// Purchased tickets and live reservations both count towards the limit,
+	// so it can't be dodged by holding tickets across several checkouts.
+	if event.MaxTicketsPerUser > 0 {
+		var held int64
+		if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("event_id = ? AND user_id = ?", event.ID, req.UserID).Count(&held).Error; err != nil {
+			return nil, errAvailability
+		}
+		if held+quantity > event.MaxTicketsPerUser {
+			return nil, purchaseLimitError("event", event.MaxTicketsPerUser, held)
+		}
+	}
+
+	// Seats offered to waitlisted users are set aside for them, so they count
+	// as taken for everyone else. Each offer sets aside a single seat, so the
+	// holder of an offer can only claim one seat on top of what is free.
+	var offer models.WaitlistEntry
+	hasOffer := tx.Scopes(models.ActiveOffers).Where("event_id = ? AND user_id = ?", event.ID, req.UserID).Order("id").First(&offer).Error == nil
+
+	var ticketsSold, offersHeld int64
+	if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("event_id = ?", event.ID).Count(&ticketsSold).Error; err != nil {
+		return nil, errAvailability
+	}
+	if err := tx.Model(&models.WaitlistEntry{}).Scopes(models.ActiveOffers).Where("event_id = ?", event.ID).Count(&offersHeld).Error; err != nil {
+		return nil, errAvailability
+	}
+	if hasOffer {
+		offersHeld--
+	}
+	if ticketsSold+offersHeld+quantity > event.Capacity {
+		return nil, errSoldOut
+	}
+
+	seats, err := lockSeats(tx, event, req.SeatIDs, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	var promoCodeID *uint
+	var discount float64
+	if req.PromoCode != "" {
+		promo, err := redeemPromoCode(tx, req.PromoCode, event.ID, req.UserID, quantity)
+		if err != nil {
+			return nil, err
+		}
+		promoCodeID = &promo.ID
+		discount = promo.DiscountFor(price)
+	}
+
+	status := "purchased"
+	var expiresAt, paidAt *time.Time
+	if req.Hold {
+		deadline := now.Add(holdDuration)
+		status = "reserved"
+		expiresAt = &deadline
+	} else {
+		paidAt = &now
+	}
+
+	tickets := make([]models.Ticket, quantity)
+	for i := range tickets {
+		tickets[i] = models.Ticket{
+			UserID:       req.UserID,
+			EventID:      event.ID,
+			TicketTypeID: ticketTypeID,
+			OrderID:      req.OrderID,
+			Status:       status,
+			Price:        price - discount,
+			PromoCodeID:  promoCodeID,
+			Discount:     discount,
+			ExpiresAt:    expiresAt,
+			PaidAt:       paidAt,
+		}
+		if seats != nil {
+			tickets[i].SeatID = &seats[i].ID
+		}
+	}
+	if err := tx.Create(&tickets).Error; err != nil {
+		return nil, err
+	}
+
+	if hasOffer {
+		if err := tx.Model(&offer).Update("status", "fulfilled").Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return tickets, nil
+}
+
+// lockSeats locks the requested seats of a reserved-seating event and checks
+// that none of them is held by another ticket. It returns nil for events
+// without a venue.
+func lockSeats(tx *gorm.DB, event models.Event, seatIDs []uint, quantity int64) ([]models.Seat, error) {
+	if event.VenueID == nil {
+		if len(seatIDs) > 0 {
+			return nil, errSeatsNotAllowed
+		}
+		return nil, nil
+	}
+	if int64(len(seatIDs)) != quantity || quantity == 0 {
+		return nil, errSeatsRequired
+	}
+
+	// Lock in primary key order so overlapping seat selections cannot deadlock.
+	var seats []models.Seat
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Joins("JOIN venue_sections ON venue_sections.id = seats.section_id").
+		Where("seats.id IN ? AND venue_sections.venue_id = ?", seatIDs, *event.VenueID).
+		Order("seats.id").
+		Find(&seats).Error; err != nil {
+		return nil, errAvailability
+	}
+	if len(seats) != len(seatIDs) {
+		return nil, errSeatNotFound
+	}
+
+	var taken int64
+	if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).
+		Where("event_id = ? AND seat_id IN ?", event.ID, seatIDs).
+		Count(&taken).Error; err != nil {
+		return nil, errAvailability
+	}
+	if taken > 0 {
+		return nil, errSeatTaken
+	}
+
+	return seats, nil
+}
+
 func GetTickets(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -60,64 +365,113 @@ func GetTicketByID(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if ticket.Status == "purchased" && (ticket.User.Email == c.GetString("email") || c.GetString("role") == "admin") {
+			code, err := utils.GenerateTicketCode(ticket)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate ticket code"})
+				return
+			}
+			ticket.Code = code
+		}
+
 		c.JSON(http.StatusOK, ticket)
 	}
 }
 
-func PurchaseTicket(db *gorm.DB) gin.HandlerFunc {
+func GetTicketQRCode(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		id := c.Param("id")
 
-		var req struct {
-			Email   string `json:"email" binding:"required"`
-			EventID uint   `json:"event_id" binding:"required"`
+		var ticket models.Ticket
+		if err := db.Preload("User").First(&ticket, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+			return
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		if ticket.User.Email != c.GetString("email") && c.GetString("role") != "admin" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
 			return
 		}
 
-		var user models.User
-		if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		if ticket.Status != "purchased" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only purchased tickets have an admission code"})
 			return
 		}
 
-		var event models.Event
-		if err := db.First(&event, req.EventID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		code, err := utils.GenerateTicketCode(ticket)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate ticket code"})
 			return
 		}
 
-		if strings.EqualFold(event.Status, "finished") || event.Date.Before(time.Now()) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot purchase a ticket for event that has already finished"})
+		png, err := qrcode.Encode(code, qrcode.Medium, 512)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
 			return
 		}
 
-		var ticketsSold int64
-		if err := db.Model(&models.Ticket{}).Where("event_id = ? AND status = ?", req.EventID, "purchased").Count(&ticketsSold).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket availability"})
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "image/png", png)
+	}
+}
+
+func PurchaseTicket(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var req struct {
+			Email        string `json:"email" binding:"required"`
+			EventID      uint   `json:"event_id" binding:"required"`
+			TicketTypeID *uint  `json:"ticket_type_id"`
+			Hold         bool   `json:"hold"`
+			PromoCode    string `json:"promo_code"`
+			SeatID       *uint  `json:"seat_id"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
-		if ticketsSold >= event.Capacity {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Event is sold out"})
+
+		var user models.User
+		if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
 
-		ticket := models.Ticket{
-			UserID:  user.ID,
-			EventID: req.EventID,
-			Status:  "purchased",
-			Price:   event.Price,
+		var seatIDs []uint
+		if req.SeatID != nil {
+			seatIDs = []uint{*req.SeatID}
 		}
 
-		if err := db.Create(&ticket).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purchase ticket"})
+		var ticket models.Ticket
+		err := db.Transaction(func(tx *gorm.DB) error {
+			tickets, err := reserveTickets(tx, ticketRequest{
+				UserID:       user.ID,
+				EventID:      req.EventID,
+				TicketTypeID: req.TicketTypeID,
+				Quantity:     1,
+				Hold:         req.Hold,
+				PromoCode:    req.PromoCode,
+				SeatIDs:      seatIDs,
+			})
+			if err != nil {
+				return err
+			}
+			ticket = tickets[0]
+			return nil
+		})
+		if err != nil {
+			respondPurchaseError(c, err, "Failed to purchase ticket")
 			return
 		}
 
+		message := "Ticket purchased successfully"
+		if req.Hold {
+			message = "Ticket reserved successfully"
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Ticket purchased successfully",
+			"message": message,
 			"ticket":  ticket,
 		})
 	}
@@ -129,6 +483,7 @@ func UpdateTicket(db *gorm.DB) gin.HandlerFunc {
 
 		var req struct {
 			Status string `json:"status" binding:"required"`
+			Reason string `json:"reason"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -145,23 +500,93 @@ func UpdateTicket(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var ticket models.Ticket
-		if err := db.Preload("Event").First(&ticket, id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 			return
 		}
 
-		if ticket.Event.Date.Before(time.Now()) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot update tickets for past events"})
+		// The ticket row is locked so concurrent updates can't both see the
+		// old status, e.g. two cancellations creating two refunds.
+		var ticket models.Ticket
+		var refund *models.Refund
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errTicketNotFound
+				}
+				return err
+			}
+			if ticket.UserID != user.ID && user.Role != "admin" {
+				return errTicketNotFound
+			}
+			if err := tx.First(&ticket.Event, ticket.EventID).Error; err != nil {
+				return err
+			}
+
+			now := time.Now()
+			if ticket.Event.HasStarted(now) {
+				return errTicketEventStarted
+			}
+			if ticket.Event.Status == models.EventStatusCancelled {
+				return errTicketEventCancelled
+			}
+			if ticket.Status == "cancelled" {
+				return errTicketCancelled
+			}
+			if ticket.Status == "expired" || (ticket.Status == "reserved" && ticket.ExpiresAt != nil && ticket.ExpiresAt.Before(now)) {
+				return errReservationExpired
+			}
+			if ticket.Status == req.Status {
+				return &purchaseError{Status: http.StatusBadRequest, Message: "Ticket is already " + req.Status}
+			}
+			// Reservations made through an order are paid for as a whole, so
+			// they can only be confirmed through ConfirmOrder.
+			if req.Status == "purchased" && ticket.OrderID != nil {
+				return errConfirmThroughOrder
+			}
+
+			if req.Status == "cancelled" && ticket.Status == "purchased" {
+				var refunds int64
+				if err := tx.Model(&models.Refund{}).Where("ticket_id = ?", ticket.ID).Count(&refunds).Error; err != nil {
+					return err
+				}
+				if refunds > 0 {
+					return errRefundExists
+				}
+				if amount := ticket.Event.RefundAmount(ticket, now); amount > 0 {
+					refund = &models.Refund{
+						TicketID: ticket.ID,
+						UserID:   ticket.UserID,
+						EventID:  ticket.EventID,
+						Amount:   amount,
+						Reason:   req.Reason,
+						Status:   "requested",
+					}
+					if err := tx.Create(refund).Error; err != nil {
+						return err
+					}
+				}
+			}
+			if req.Status == "purchased" && ticket.PaidAt == nil {
+				ticket.PaidAt = &now
+			}
+
+			ticket.Status = req.Status
+			ticket.ExpiresAt = nil
+			return tx.Omit("Event").Save(&ticket).Error
+		})
+		if err != nil {
+			respondPurchaseError(c, err, "Failed to update ticket")
 			return
 		}
 
-		ticket.Status = req.Status
-		if err := db.Save(&ticket).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
-			return
+		if req.Status == "cancelled" {
+			if err := models.OfferWaitlistSeats(db, ticket.EventID); err != nil {
+				log.Println("Failed to offer freed seat to waitlist:", err)
+			}
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Ticket updated successfully", "ticket": ticket})
+		c.JSON(http.StatusOK, gin.H{"message": "Ticket updated successfully", "ticket": ticket, "refund": refund})
 	}
 }
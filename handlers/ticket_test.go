@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"ticketink/migrations"
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to the MySQL database named by TICKETINK_TEST_DSN. The
+// purchase tests depend on real row locking, so they are skipped rather
+// than run against a fake store when no database is configured.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TICKETINK_TEST_DSN")
+	if dsn == "" {
+		t.Skip("TICKETINK_TEST_DSN is not set, e.g. user:pass@tcp(127.0.0.1:3306)/ticketink_test?charset=utf8mb4&parseTime=True&loc=Local")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal("connecting to test database:", err)
+	}
+	migrations.RunMigrations(db)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(50)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestPurchaseTicketNeverOversells(t *testing.T) {
+	db := testDB(t)
+	gin.SetMode(gin.TestMode)
+
+	const capacity = 50
+	const buyers = 300
+
+	suffix := time.Now().UnixNano()
+	now := time.Now()
+	event := models.Event{
+		Title:       fmt.Sprintf("Oversell test %d", suffix),
+		StartsAt:    now.Add(24 * time.Hour),
+		EndsAt:      now.Add(26 * time.Hour),
+		TimeZone:    "UTC",
+		Location:    "Test hall",
+		Capacity:    capacity,
+		Status:      models.EventStatusScheduled,
+		PublishedAt: &now,
+	}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatal("creating event:", err)
+	}
+
+	users := make([]models.User, buyers)
+	for i := range users {
+		users[i] = models.User{
+			Name:     "Buyer",
+			Email:    fmt.Sprintf("buyer-%d-%d@example.com", suffix, i),
+			Password: "x",
+			Role:     "user",
+		}
+	}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal("creating users:", err)
+	}
+
+	t.Cleanup(func() {
+		db.Unscoped().Where("event_id = ?", event.ID).Delete(&models.Ticket{})
+		db.Unscoped().Delete(&event)
+		db.Unscoped().Delete(&users)
+	})
+
+	router := gin.New()
+	router.POST("/tickets", PurchaseTicket(db))
+
+	var mu sync.Mutex
+	statuses := map[int]int{}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for _, user := range users {
+		wg.Add(1)
+		go func(email string) {
+			defer wg.Done()
+			body, _ := json.Marshal(gin.H{"email": email, "event_id": event.ID})
+			<-start
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/tickets", bytes.NewReader(body)))
+
+			mu.Lock()
+			statuses[w.Code]++
+			mu.Unlock()
+		}(user.Email)
+	}
+	close(start)
+	wg.Wait()
+
+	var sold int64
+	db.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("event_id = ?", event.ID).Count(&sold)
+
+	if sold > capacity {
+		t.Fatalf("sold %d tickets for an event with capacity %d", sold, capacity)
+	}
+	if statuses[http.StatusOK] != int(sold) {
+		t.Errorf("%d purchases succeeded but %d tickets exist", statuses[http.StatusOK], sold)
+	}
+	// Every buyer queues on the same event row, so with more buyers than
+	// seats the event must sell out exactly, unless some gave up on a lock.
+	if sold < capacity && statuses[http.StatusConflict] == 0 {
+		t.Errorf("sold only %d of %d tickets without any lock conflicts, statuses %v", sold, capacity, statuses)
+	}
+	for status := range statuses {
+		if status != http.StatusOK && status != http.StatusBadRequest && status != http.StatusConflict {
+			t.Errorf("unexpected response status %d (%d times)", status, statuses[status])
+		}
+	}
+}
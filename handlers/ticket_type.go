@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TicketTypeRequest struct {
+	Name       string  `json:"name" binding:"required"`
+	Price      float64 `json:"price" binding:"min=0"`
+	Capacity   int64   `json:"capacity" binding:"min=0"`
+	MaxPerUser int64   `json:"max_per_user" binding:"min=0"` // 0 means no limit
+	SalesStart string  `json:"sales_start"`                  // RFC 3339, optional
+	SalesEnd   string  `json:"sales_end"`                    // RFC 3339, optional
+}
+
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (req TicketTypeRequest) apply(ticketType *models.TicketType) error {
+	salesStart, err := parseOptionalTime(req.SalesStart)
+	if err != nil {
+		return errors.New("Invalid sales_start format, use RFC 3339")
+	}
+	salesEnd, err := parseOptionalTime(req.SalesEnd)
+	if err != nil {
+		return errors.New("Invalid sales_end format, use RFC 3339")
+	}
+	if salesStart != nil && salesEnd != nil && !salesEnd.After(*salesStart) {
+		return errors.New("sales_end must be after sales_start")
+	}
+
+	ticketType.Name = req.Name
+	ticketType.Price = req.Price
+	ticketType.Capacity = req.Capacity
+	ticketType.MaxPerUser = req.MaxPerUser
+	ticketType.SalesStart = salesStart
+	ticketType.SalesEnd = salesEnd
+	return nil
+}
+
+var errTierCapacityExceeded = errors.New("Ticket type capacities exceed the event capacity")
+
+// checkTierCapacity rejects ticketType when the capacities of all the
+// event's tiers together would exceed the event capacity, which stays the
+// binding limit on sales. The event row must already be locked by tx.
+func checkTierCapacity(tx *gorm.DB, event models.Event, ticketType models.TicketType) error {
+	var others int64
+	if err := tx.Model(&models.TicketType{}).
+		Where("event_id = ? AND id <> ?", event.ID, ticketType.ID).
+		Select("COALESCE(SUM(capacity), 0)").
+		Scan(&others).Error; err != nil {
+		return err
+	}
+	if others+ticketType.Capacity > event.Capacity {
+		return errTierCapacityExceeded
+	}
+	return nil
+}
+
+// eventTierCapacity is the combined capacity of all of an event's tiers, the
+// lowest the event capacity may go.
+func eventTierCapacity(tx *gorm.DB, eventID uint) (int64, error) {
+	var total int64
+	err := tx.Model(&models.TicketType{}).Where("event_id = ?", eventID).Select("COALESCE(SUM(capacity), 0)").Scan(&total).Error
+	return total, err
+}
+
+func ListTicketTypes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		query := db
+		if c.GetString("role") != "admin" {
+			query = query.Scopes(models.Published)
+		}
+
+		var event models.Event
+		if err := query.First(&event, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		var ticketTypes []models.TicketType
+		if err := db.Where("event_id = ?", event.ID).Order("price").Find(&ticketTypes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ticket types"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"ticket_types": ticketTypes})
+	}
+}
+
+func CreateTicketType(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var event models.Event
+		if err := db.First(&event, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		var req TicketTypeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ticketType := models.TicketType{EventID: event.ID}
+		if err := req.apply(&ticketType); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, event.ID).Error; err != nil {
+				return err
+			}
+			if err := checkTierCapacity(tx, event, ticketType); err != nil {
+				return err
+			}
+			return tx.Create(&ticketType).Error
+		})
+		if errors.Is(err, errTierCapacityExceeded) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ticket type"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, ticketType)
+	}
+}
+
+func UpdateTicketType(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var ticketType models.TicketType
+		if err := db.First(&ticketType, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found"})
+			return
+		}
+
+		var req TicketTypeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := req.apply(&ticketType); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var event models.Event
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, ticketType.EventID).Error; err != nil {
+				return err
+			}
+			if err := checkTierCapacity(tx, event, ticketType); err != nil {
+				return err
+			}
+			return tx.Save(&ticketType).Error
+		})
+		if errors.Is(err, errTierCapacityExceeded) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket type"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ticketType)
+	}
+}
+
+func DeleteTicketType(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var ticketType models.TicketType
+		if err := db.First(&ticketType, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found"})
+			return
+		}
+
+		var ticketsSold int64
+		db.Model(&models.Ticket{}).Where("ticket_type_id = ?", ticketType.ID).Count(&ticketsSold)
+		if ticketsSold > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a ticket type with sold tickets"})
+			return
+		}
+
+		if err := db.Delete(&ticketType).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ticket type"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Ticket type deleted successfully"})
+	}
+}
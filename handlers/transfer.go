@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errTransferNotFound   = &purchaseError{Status: http.StatusNotFound, Message: "Transfer not found"}
+	errTransferNotPending = &purchaseError{Status: http.StatusBadRequest, Message: "Transfer is no longer pending"}
+	errTransferExpired    = &purchaseError{Status: http.StatusBadRequest, Message: "Transfer offer has expired"}
+	errTransferStale      = &purchaseError{Status: http.StatusConflict, Message: "Ticket is no longer transferable by the sender"}
+	errTransferEventOver  = &purchaseError{Status: http.StatusBadRequest, Message: "Cannot accept transfers for events that have already started or were cancelled"}
+)
+
+func TransferTicket(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var req struct {
+			Email string `json:"email" binding:"required,email"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var ticket models.Ticket
+		if err := db.Preload("Event").First(&ticket, id).Error; err != nil || ticket.UserID != user.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+			return
+		}
+
+		if ticket.Status != "purchased" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only purchased tickets can be transferred"})
+			return
+		}
+		if ticket.Event.Closed() || ticket.Event.HasStarted(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot transfer tickets for events that have already started or were cancelled"})
+			return
+		}
+		if strings.EqualFold(req.Email, user.Email) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot transfer a ticket to yourself"})
+			return
+		}
+
+		var pending models.TicketTransfer
+		if err := db.Where("ticket_id = ? AND status = ? AND expires_at > ?", ticket.ID, "pending", time.Now()).First(&pending).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ticket already has a pending transfer"})
+			return
+		}
+
+		transfer := models.TicketTransfer{
+			TicketID:   ticket.ID,
+			FromUserID: user.ID,
+			ToEmail:    strings.ToLower(req.Email),
+			Status:     "pending",
+			ExpiresAt:  time.Now().Add(models.TransferOfferDuration),
+		}
+		if err := db.Create(&transfer).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":  "Transfer offer sent successfully",
+			"transfer": transfer,
+		})
+	}
+}
+
+func GetTransfers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var incoming, outgoing []models.TicketTransfer
+		db.Preload("Ticket.Event").Where("to_email = ? AND status = ? AND expires_at > ?", strings.ToLower(user.Email), "pending", time.Now()).Find(&incoming)
+		db.Preload("Ticket.Event").Where("from_user_id = ?", user.ID).Order("id DESC").Find(&outgoing)
+
+		c.JSON(http.StatusOK, gin.H{
+			"incoming": incoming,
+			"outgoing": outgoing,
+		})
+	}
+}
+
+func GetTicketTransferHistory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var ticket models.Ticket
+		if err := db.First(&ticket, id).Error; err != nil || (ticket.UserID != user.ID && user.Role != "admin") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+			return
+		}
+
+		var transfers []models.TicketTransfer
+		db.Where("ticket_id = ?", ticket.ID).Order("id").Find(&transfers)
+
+		c.JSON(http.StatusOK, gin.H{"transfers": transfers})
+	}
+}
+
+func AcceptTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var transfer models.TicketTransfer
+		var ticket models.Ticket
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, id).Error; err != nil || !strings.EqualFold(transfer.ToEmail, user.Email) {
+				return errTransferNotFound
+			}
+			if transfer.Status != "pending" {
+				return errTransferNotPending
+			}
+			now := time.Now()
+			if transfer.ExpiresAt.Before(now) {
+				return errTransferExpired
+			}
+
+			// The event is locked before the ticket, in the same order as
+			// purchases, so the recipient's holdings can't change underneath
+			// the limit checks below.
+			if err := tx.Select("event_id").First(&ticket, transfer.TicketID).Error; err != nil {
+				return errTransferStale
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket.Event, ticket.EventID).Error; err != nil {
+				return errTransferStale
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket, transfer.TicketID).Error; err != nil {
+				return errTransferStale
+			}
+			if ticket.UserID != transfer.FromUserID || ticket.Status != "purchased" {
+				return errTransferStale
+			}
+			if ticket.Event.Closed() || ticket.Event.HasStarted(now) {
+				return errTransferEventOver
+			}
+
+			if err := checkTransferLimits(tx, ticket, user.ID); err != nil {
+				return err
+			}
+
+			ticket.UserID = user.ID
+			ticket.CodeVersion++
+			if err := tx.Omit("Event").Save(&ticket).Error; err != nil {
+				return err
+			}
+
+			transfer.Status = "accepted"
+			transfer.ToUserID = &user.ID
+			transfer.RespondedAt = &now
+			return tx.Save(&transfer).Error
+		})
+		if err != nil {
+			respondPurchaseError(c, err, "Failed to accept transfer")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Transfer accepted successfully",
+			"ticket":  ticket,
+		})
+	}
+}
+
+// checkTransferLimits rejects a transfer that would take the recipient past
+// the per-user limits of the ticket's event or tier. The event row must
+// already be locked by tx.
+func checkTransferLimits(tx *gorm.DB, ticket models.Ticket, userID uint) error {
+	if ticket.TicketTypeID != nil {
+		var ticketType models.TicketType
+		if err := tx.First(&ticketType, *ticket.TicketTypeID).Error; err != nil {
+			return err
+		}
+		if ticketType.MaxPerUser > 0 {
+			var held int64
+			if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("ticket_type_id = ? AND user_id = ?", ticketType.ID, userID).Count(&held).Error; err != nil {
+				return err
+			}
+			if held+1 > ticketType.MaxPerUser {
+				return purchaseLimitError("ticket type", ticketType.MaxPerUser, held)
+			}
+		}
+	}
+
+	if ticket.Event.MaxTicketsPerUser > 0 {
+		var held int64
+		if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("event_id = ? AND user_id = ?", ticket.EventID, userID).Count(&held).Error; err != nil {
+			return err
+		}
+		if held+1 > ticket.Event.MaxTicketsPerUser {
+			return purchaseLimitError("event", ticket.Event.MaxTicketsPerUser, held)
+		}
+	}
+	return nil
+}
+
+// closeTransfer ends a pending transfer without moving the ticket. Only the
+// recipient may decline and only the sender may cancel.
+func closeTransfer(db *gorm.DB, c *gin.Context, next string) {
+	id := c.Param("id")
+
+	user, err := currentUser(db, c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var transfer models.TicketTransfer
+	if err := db.First(&transfer, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+	if (next == "declined" && !strings.EqualFold(transfer.ToEmail, user.Email)) ||
+		(next == "cancelled" && transfer.FromUserID != user.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+	if transfer.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transfer is no longer pending"})
+		return
+	}
+
+	now := time.Now()
+	transfer.Status = next
+	transfer.RespondedAt = &now
+	if err := db.Save(&transfer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer " + next + " successfully", "transfer": transfer})
+}
+
+func DeclineTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		closeTransfer(db, c, "declined")
+	}
+}
+
+func CancelTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		closeTransfer(db, c, "cancelled")
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func UpdateUserRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var req struct {
+			Role string `json:"role" binding:"required,oneof=user scanner admin"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if user.Email == c.GetString("email") && req.Role != "admin" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot remove your own admin role"})
+			return
+		}
+
+		user.Role = req.Role
+		if err := db.Save(&user).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "User role updated successfully",
+			"user": gin.H{
+				"id":    user.ID,
+				"email": user.Email,
+				"role":  user.Role,
+				"name":  user.Name,
+			},
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type VenueRowRequest struct {
+	Label string `json:"label" binding:"required,max=10"`
+	Seats int    `json:"seats" binding:"required,min=1,max=500"`
+}
+
+type VenueSectionRequest struct {
+	Name string            `json:"name" binding:"required,max=100"`
+	Rows []VenueRowRequest `json:"rows" binding:"required,min=1,dive"`
+}
+
+type VenueRequest struct {
+	Name     string                `json:"name" binding:"required,max=200"`
+	Address  string                `json:"address" binding:"max=255"`
+	Sections []VenueSectionRequest `json:"sections" binding:"required,min=1,dive"`
+}
+
+func ListVenues(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var venues []models.Venue
+		if err := db.Order("name").Find(&venues).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch venues"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"venues": venues})
+	}
+}
+
+func GetVenue(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var venue models.Venue
+		if err := db.Preload("Sections.Seats").First(&venue, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Venue not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, venue)
+	}
+}
+
+// CreateVenue builds a venue and its full seating layout from the given
+// sections and rows. Seats in a row are numbered from 1.
+func CreateVenue(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req VenueRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var existing models.Venue
+		if err := db.Where("name = ?", req.Name).First(&existing).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Venue name must be unique"})
+			return
+		}
+
+		venue := models.Venue{Name: req.Name, Address: req.Address}
+		for _, sectionReq := range req.Sections {
+			section := models.VenueSection{Name: sectionReq.Name}
+			rows := map[string]bool{}
+			for _, row := range sectionReq.Rows {
+				if rows[row.Label] {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate row " + row.Label + " in section " + sectionReq.Name})
+					return
+				}
+				rows[row.Label] = true
+				for number := 1; number <= row.Seats; number++ {
+					section.Seats = append(section.Seats, models.Seat{Row: row.Label, Number: number})
+				}
+			}
+			venue.Sections = append(venue.Sections, section)
+		}
+
+		if err := db.Create(&venue).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create venue"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, venue)
+	}
+}
+
+func GetEventSeats(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		query := db
+		if c.GetString("role") != "admin" {
+			query = query.Scopes(models.Published)
+		}
+
+		var event models.Event
+		if err := query.Preload("Venue.Sections.Seats").First(&event, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+
+		if event.Venue == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Event does not have reserved seating"})
+			return
+		}
+
+		var takenSeatIDs []uint
+		db.Model(&models.Ticket{}).Scopes(models.HoldsInventory).
+			Where("event_id = ? AND seat_id IS NOT NULL", event.ID).
+			Pluck("seat_id", &takenSeatIDs)
+		taken := make(map[uint]bool, len(takenSeatIDs))
+		for _, seatID := range takenSeatIDs {
+			taken[seatID] = true
+		}
+
+		type seatAvailability struct {
+			ID        uint   `json:"id"`
+			Row       string `json:"row"`
+			Number    int    `json:"number"`
+			Available bool   `json:"available"`
+		}
+		type sectionAvailability struct {
+			ID    uint               `json:"id"`
+			Name  string             `json:"name"`
+			Seats []seatAvailability `json:"seats"`
+		}
+
+		available := 0
+		sections := make([]sectionAvailability, 0, len(event.Venue.Sections))
+		for _, section := range event.Venue.Sections {
+			result := sectionAvailability{ID: section.ID, Name: section.Name, Seats: []seatAvailability{}}
+			for _, seat := range section.Seats {
+				free := !taken[seat.ID]
+				if free {
+					available++
+				}
+				result.Seats = append(result.Seats, seatAvailability{ID: seat.ID, Row: seat.Row, Number: seat.Number, Available: free})
+			}
+			sections = append(sections, result)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"event_id":  event.ID,
+			"venue_id":  event.Venue.ID,
+			"venue":     event.Venue.Name,
+			"available": available,
+			"sections":  sections,
+		})
+	}
+}
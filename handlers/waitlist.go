@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"ticketink/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errWaitlistClosed     = &purchaseError{Status: http.StatusBadRequest, Message: "Cannot join the waitlist for event that has already finished or was cancelled"}
+	errWaitlistJoined     = &purchaseError{Status: http.StatusConflict, Message: "Already on the waitlist for this event"}
+	errWaitlistNotSoldOut = &purchaseError{Status: http.StatusBadRequest, Message: "Event is not sold out, purchase a ticket instead"}
+)
+
+func waitlistPosition(db *gorm.DB, entry models.WaitlistEntry) int64 {
+	if entry.Status != "waiting" {
+		return 0
+	}
+	var position int64
+	db.Model(&models.WaitlistEntry{}).Where("event_id = ? AND status = ? AND id <= ?", entry.EventID, "waiting", entry.ID).Count(&position)
+	return position
+}
+
+func JoinWaitlist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		// The event row is locked so that the sold-out check cannot race a
+		// cancellation or a capacity increase that would hand out the seat.
+		var entry models.WaitlistEntry
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var event models.Event
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(models.Published).First(&event, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errEventNotFound
+				}
+				return lockError(err)
+			}
+
+			if event.Closed() || event.HasStarted(time.Now()) {
+				return errWaitlistClosed
+			}
+
+			var existing models.WaitlistEntry
+			if err := tx.Where("event_id = ? AND user_id = ? AND status IN ?", event.ID, user.ID, []string{"waiting", "offered"}).First(&existing).Error; err == nil {
+				return errWaitlistJoined
+			}
+
+			var ticketsSold, offersHeld int64
+			if err := tx.Model(&models.Ticket{}).Scopes(models.HoldsInventory).Where("event_id = ?", event.ID).Count(&ticketsSold).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.WaitlistEntry{}).Scopes(models.ActiveOffers).Where("event_id = ?", event.ID).Count(&offersHeld).Error; err != nil {
+				return err
+			}
+			if ticketsSold+offersHeld < event.Capacity {
+				return errWaitlistNotSoldOut
+			}
+
+			entry = models.WaitlistEntry{
+				EventID: event.ID,
+				UserID:  user.ID,
+				Status:  "waiting",
+			}
+			return tx.Create(&entry).Error
+		})
+		if err != nil {
+			respondPurchaseError(c, err, "Failed to join waitlist")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":  "Joined the waitlist successfully",
+			"entry":    entry,
+			"position": waitlistPosition(db, entry),
+		})
+	}
+}
+
+func GetWaitlistStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var entry models.WaitlistEntry
+		if err := db.Where("event_id = ? AND user_id = ?", id, user.ID).Order("id DESC").First(&entry).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not on the waitlist for this event"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"entry":    entry,
+			"position": waitlistPosition(db, entry),
+		})
+	}
+}
+
+func LeaveWaitlist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		user, err := currentUser(db, c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var entry models.WaitlistEntry
+		if err := db.Where("event_id = ? AND user_id = ? AND status IN ?", id, user.ID, []string{"waiting", "offered"}).First(&entry).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not on the waitlist for this event"})
+			return
+		}
+
+		wasOffered := entry.Status == "offered"
+		entry.Status = "left"
+		entry.OfferExpiresAt = nil
+		if err := db.Save(&entry).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+			return
+		}
+
+		if wasOffered {
+			if err := models.OfferWaitlistSeats(db, entry.EventID); err != nil {
+				log.Println("Failed to pass declined offer down the waitlist:", err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Left the waitlist successfully"})
+	}
+}
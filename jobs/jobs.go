@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"time"
+
+	"ticketink/models"
+
+	"gorm.io/gorm"
+)
+
+// Start launches the background jobs that keep time-based state current.
+func Start(db *gorm.DB) {
+	go every(time.Minute, func() {
+		models.ReleaseExpiredHolds(db)
+		models.ProcessWaitlists(db)
+	})
+	go every(time.Minute, func() {
+		models.PublishScheduledEvents(db)
+		models.AdvanceEventLifecycles(db)
+	})
+	go every(time.Minute, func() { models.SendPendingNotifications(db) })
+}
+
+func every(interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		job()
+	}
+}
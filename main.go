@@ -3,8 +3,10 @@ package main
 import (
 	"log"
 	"ticketink/config"
+	"ticketink/jobs"
 	"ticketink/migrations"
 	"ticketink/routes"
+	"ticketink/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,7 +17,15 @@ func main() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+	if err := utils.LoadTicketCodeKey(); err != nil {
+		log.Fatal("Failed to load ticket code key:", err)
+	}
+
 	migrations.RunMigrations(db)
+	jobs.Start(db)
 
 	r := gin.Default()
 
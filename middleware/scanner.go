@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ScannerOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		if role != "scanner" && role != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Scanner access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -9,10 +9,28 @@ import (
 )
 
 func RunMigrations(db *gorm.DB) {
+	migrateEventSchedule(db)
+
 	err := db.AutoMigrate(
 		&models.User{},
 		&models.Ticket{},
 		&models.Event{},
+		&models.EventMedia{},
+		&models.TicketType{},
+		&models.Order{},
+		&models.WaitlistEntry{},
+		&models.PromoCode{},
+		&models.Refund{},
+		&models.TicketTransfer{},
+		&models.CheckIn{},
+		&models.Category{},
+		&models.Venue{},
+		&models.VenueSection{},
+		&models.Seat{},
+		&models.EventSeries{},
+		&models.EventStatusTransition{},
+		&models.Notification{},
+		&models.CalendarFeed{},
 		&models.Report{},
 		&models.TokenBlacklist{},
 	)
@@ -22,6 +40,21 @@ func RunMigrations(db *gorm.DB) {
 		log.Println("Database migrated successfully!")
 	}
 
+	// Every ticket sold before reservations existed was paid for at creation.
+	db.Model(&models.Ticket{}).
+		Where("paid_at IS NULL AND status IN ?", []string{"purchased", "cancelled"}).
+		Update("paid_at", gorm.Expr("created_at"))
+
+	// Normalize the status values used before the event state machine.
+	db.Model(&models.Event{}).Where("status IN ?", []string{"Active", "active"}).Update("status", models.EventStatusScheduled)
+	db.Model(&models.Event{}).Where("status IN ?", []string{"Ongoing", "ongoing"}).Update("status", models.EventStatusOngoing)
+	db.Model(&models.Event{}).Where("status IN ?", []string{"Finished", "completed"}).Update("status", models.EventStatusFinished)
+
+	// Events from before the draft workflow were published when created.
+	db.Model(&models.Event{}).
+		Where("published_at IS NULL AND status <> ?", models.EventStatusDraft).
+		Update("published_at", gorm.Expr("created_at"))
+
 	var adminCount int64
 	db.Model(&models.User{}).Where("role = ?", "admin").Count(&adminCount)
 
@@ -49,3 +82,45 @@ func RunMigrations(db *gorm.DB) {
 		log.Println("Database migrated successfully!")
 	}
 }
+
+// migrateEventSchedule upgrades events created before they had start and end
+// times. Titles used to be unique on their own and events only stored a date;
+// the date becomes the start time and the event runs until the end of that
+// day. It must run before AutoMigrate.
+func migrateEventSchedule(db *gorm.DB) {
+	migrator := db.Migrator()
+	if !migrator.HasTable("events") {
+		return
+	}
+
+	dropIndexIfExists(db, "events", "title")
+
+	if migrator.HasColumn("events", "date") && !migrator.HasColumn("events", "starts_at") {
+		if err := migrator.RenameColumn("events", "date", "starts_at"); err != nil {
+			log.Fatal("Failed to rename event date column:", err)
+		}
+		if err := db.Exec("ALTER TABLE events ADD COLUMN ends_at DATETIME(3) NULL").Error; err != nil {
+			log.Fatal("Failed to add event end time column:", err)
+		}
+		db.Exec("UPDATE events SET ends_at = DATE_ADD(starts_at, INTERVAL 1 DAY)")
+		dropIndexIfExists(db, "events", "idx_event_title_date")
+	}
+
+	if migrator.HasTable("event_series") && migrator.HasColumn("event_series", "start_date") {
+		if err := migrator.RenameColumn("event_series", "start_date", "starts_at"); err != nil {
+			log.Fatal("Failed to rename series start column:", err)
+		}
+		db.Exec("ALTER TABLE event_series ADD COLUMN duration_minutes BIGINT NOT NULL DEFAULT 1440")
+	}
+}
+
+func dropIndexIfExists(db *gorm.DB, table, index string) {
+	var count int64
+	db.Raw("SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?", table, index).Scan(&count)
+	if count == 0 {
+		return
+	}
+	if err := db.Exec("ALTER TABLE " + table + " DROP INDEX " + index).Error; err != nil {
+		log.Println("Failed to drop index", index, "on", table, ":", err)
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// CalendarFeed holds the secret token of a user's personal iCalendar feed.
+// Anyone with the token can read the feed, so it can be reset.
+type CalendarFeed struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;uniqueIndex"`
+	Token     string    `gorm:"size:64;not null;uniqueIndex"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type Category struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"size:100;not null;unique"`
+	Slug      string    `gorm:"size:100;not null;unique"` // used by ?category= filters
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+type CategoryCount struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Slug   string `json:"slug"`
+	Events int64  `json:"events"`
+}
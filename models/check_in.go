@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type CheckIn struct {
+	ID          uint      `gorm:"primaryKey"`
+	TicketID    uint      `gorm:"not null;uniqueIndex"` // a ticket can only be admitted once
+	Ticket      Ticket    `gorm:"foreignKey:TicketID"`
+	EventID     uint      `gorm:"not null;index"`
+	Gate        string    `gorm:"size:50;not null"`
+	ScannedBy   string    `gorm:"size:100;not null"` // email of the scanner account
+	CheckedInAt time.Time `gorm:"not null"`
+}
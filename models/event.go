@@ -7,16 +7,94 @@ import (
 )
 
 type Event struct {
-	ID          uint           `gorm:"primaryKey"`
-	Title       string         `gorm:"size:200;not null;unique"`
-	Description string         `gorm:"type:text"`
-	Date        time.Time      `gorm:"not null"`
-	Location    string         `gorm:"size:255;not null"`
-	Price       float64        `gorm:"not null;check:price >= 0"`
-	Capacity    int64          `gorm:"not null;check:capacity >= 0"`
-	Status      string         `gorm:"size:20;not null"` // e.g., "active", "ongoing", "completed"
-	CreatedAt   time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt   gorm.DeletedAt `gorm:"index"`
-	Tickets     []Ticket       `gorm:"constraint:OnDelete:CASCADE"`
+	ID                uint           `gorm:"primaryKey"`
+	Title             string         `gorm:"size:200;not null;uniqueIndex:idx_event_title_start"`
+	Description       string         `gorm:"type:text"`
+	StartsAt          time.Time      `gorm:"not null;index;uniqueIndex:idx_event_title_start"`
+	EndsAt            time.Time      `gorm:"not null"`
+	TimeZone          string         `gorm:"size:64;not null;default:UTC"` // IANA name the event is scheduled in, e.g. "Asia/Jakarta"
+	SalesStart        *time.Time     // nil means on sale as soon as the event is created
+	SalesEnd          *time.Time     // nil means on sale until the event starts
+	Location          string         `gorm:"size:255;not null"`
+	VenueID           *uint          `gorm:"index"` // set for events with reserved seating
+	Venue             *Venue         `gorm:"foreignKey:VenueID"`
+	SeriesID          *uint          `gorm:"index"` // set for occurrences of a recurring series
+	Price             float64        `gorm:"not null;check:price >= 0"`
+	Capacity          int64          `gorm:"not null;check:capacity >= 0"`
+	MaxTicketsPerUser int64          `gorm:"not null;default:0;check:max_tickets_per_user >= 0"` // 0 means no limit
+	Status            string         `gorm:"size:20;not null;index"`                             // one of the EventStatus constants
+	PublishAt         *time.Time     `gorm:"index"`                                              // when a draft is scheduled to be published
+	PublishedAt       *time.Time     `gorm:"index"`
+	RefundsDisabled   bool           `gorm:"not null;default:false"`
+	RefundCutoffDays  int            `gorm:"not null;default:0;check:refund_cutoff_days >= 0"` // full refund until this many days before the event, none after
+	ScheduleVersion   int            `gorm:"not null;default:0"`                               // bumped whenever the time, place or status changes
+	CreatedAt         time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+	Tickets           []Ticket       `gorm:"constraint:OnDelete:CASCADE"`
+	TicketTypes       []TicketType   `gorm:"constraint:OnDelete:CASCADE"`
+	Categories        []Category     `gorm:"many2many:event_categories;constraint:OnDelete:CASCADE"`
+	Media             []EventMedia   `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// TrackScheduleChange bumps ScheduleVersion when the start, end, location
+// or venue differ from before. Status changes are counted by
+// TransitionWithReason.
+func (e *Event) TrackScheduleChange(before Event) {
+	sameVenue := e.VenueID == before.VenueID ||
+		(e.VenueID != nil && before.VenueID != nil && *e.VenueID == *before.VenueID)
+	if !e.StartsAt.Equal(before.StartsAt) || !e.EndsAt.Equal(before.EndsAt) || e.Location != before.Location || !sameVenue {
+		e.ScheduleVersion++
+	}
+}
+
+// Zone returns the event's time zone, falling back to UTC when the
+// stored name is unknown.
+func (e Event) Zone() *time.Location {
+	if loc, err := time.LoadLocation(e.TimeZone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Ticket sales states returned by Event.SalesStatus.
+const (
+	SalesNotYetOpen = "not_yet_on_sale"
+	SalesOpen       = "on_sale"
+	SalesClosed     = "sales_closed"
+)
+
+// SalesStatus reports whether tickets for the event can be bought at the
+// given time according to its sales window.
+func (e Event) SalesStatus(at time.Time) string {
+	switch {
+	case e.SalesStart != nil && at.Before(*e.SalesStart):
+		return SalesNotYetOpen
+	case e.SalesEnd != nil && !at.Before(*e.SalesEnd), e.HasStarted(at):
+		return SalesClosed
+	}
+	return SalesOpen
+}
+
+// HasStarted reports whether the event has started at the given time.
+func (e Event) HasStarted(at time.Time) bool {
+	return !at.Before(e.StartsAt)
+}
+
+// HasEnded reports whether the event is over at the given time.
+func (e Event) HasEnded(at time.Time) bool {
+	return !at.Before(e.EndsAt)
+}
+
+// RefundAmount returns how much of the ticket's price is refundable if it is
+// cancelled at the given time under the event's refund policy.
+func (e Event) RefundAmount(ticket Ticket, at time.Time) float64 {
+	if e.RefundsDisabled || ticket.PaidAt == nil {
+		return 0
+	}
+	cutoff := e.StartsAt.In(e.Zone()).AddDate(0, 0, -e.RefundCutoffDays)
+	if at.After(cutoff) {
+		return 0
+	}
+	return ticket.Price
 }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type EventMedia struct {
+	ID           uint      `gorm:"primaryKey"`
+	EventID      uint      `gorm:"not null;index"`
+	Filename     string    `gorm:"size:255;not null"` // original name of the uploaded file
+	ContentType  string    `gorm:"size:50;not null"`
+	Size         int64     `gorm:"not null"`
+	Width        int       `gorm:"not null"`
+	Height       int       `gorm:"not null"`
+	StorageKey   string    `gorm:"size:255;not null"`
+	ThumbnailKey string    `gorm:"size:255;not null"`
+	Position     int       `gorm:"not null;default:0"` // gallery order, lowest first
+	URL          string    `gorm:"-"`                  // filled in by handlers from the storage backend
+	ThumbnailURL string    `gorm:"-"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
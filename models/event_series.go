@@ -0,0 +1,115 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxSeriesOccurrences caps how many events a series without an end date or
+// count generates.
+const MaxSeriesOccurrences = 104
+
+type EventSeries struct {
+	ID                uint           `gorm:"primaryKey"`
+	Title             string         `gorm:"size:200;not null"`
+	Description       string         `gorm:"type:text"`
+	Location          string         `gorm:"size:255;not null"`
+	VenueID           *uint          `gorm:"index"`
+	Price             float64        `gorm:"not null;check:price >= 0"`
+	Capacity          int64          `gorm:"not null;check:capacity >= 0"`
+	MaxTicketsPerUser int64          `gorm:"not null;default:0;check:max_tickets_per_user >= 0"` // copied to every occurrence, 0 means no limit
+	Frequency         string         `gorm:"size:20;not null"`                                   // "weekly", "monthly" or "dates"
+	Interval          int            `gorm:"not null;default:1"`
+	StartsAt          time.Time      `gorm:"not null"` // start of the first occurrence
+	DurationMinutes   int            `gorm:"not null;check:duration_minutes > 0"`
+	TimeZone          string         `gorm:"size:64;not null;default:UTC"` // occurrences keep their wall-clock time in this zone
+	Until             *time.Time     // last possible occurrence, inclusive
+	Count             int            `gorm:"not null;default:0"`        // 0 means until Until or MaxSeriesOccurrences
+	Dates             []string       `gorm:"type:text;serializer:json"` // explicit dates (YYYY-MM-DD) when Frequency is "dates"
+	Exclusions        []string       `gorm:"type:text;serializer:json"` // dates (YYYY-MM-DD) to skip
+	Events            []Event        `gorm:"foreignKey:SeriesID"`
+	CreatedAt         time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+}
+
+// Zone returns the series' time zone, falling back to UTC when the stored
+// name is unknown.
+func (s EventSeries) Zone() *time.Location {
+	if loc, err := time.LoadLocation(s.TimeZone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Duration returns how long every occurrence lasts.
+func (s EventSeries) Duration() time.Duration {
+	return time.Duration(s.DurationMinutes) * time.Minute
+}
+
+// Occurrences expands the recurrence rule into the start time of every event
+// in the series, in chronological order. Recurrence is computed in the
+// series' time zone so occurrences keep their local start time across
+// daylight saving changes.
+func (s EventSeries) Occurrences() []time.Time {
+	loc := s.Zone()
+	first := s.StartsAt.In(loc)
+
+	excluded := make(map[string]bool, len(s.Exclusions))
+	for _, date := range s.Exclusions {
+		excluded[date] = true
+	}
+
+	var occurrences []time.Time
+	add := func(t time.Time) {
+		if !excluded[t.Format("2006-01-02")] {
+			occurrences = append(occurrences, t)
+		}
+	}
+
+	if s.Frequency == "dates" {
+		for _, date := range s.Dates {
+			if d, err := time.ParseInLocation("2006-01-02", date, loc); err == nil {
+				add(time.Date(d.Year(), d.Month(), d.Day(), first.Hour(), first.Minute(), first.Second(), 0, loc))
+			}
+		}
+		sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].Before(occurrences[j]) })
+		return occurrences
+	}
+
+	interval := s.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	generated := 0
+	for step := 0; generated < MaxSeriesOccurrences; step++ {
+		var t time.Time
+		switch s.Frequency {
+		case "weekly":
+			t = first.AddDate(0, 0, 7*interval*step)
+		case "monthly":
+			t = first.AddDate(0, interval*step, 0)
+			// Months without this day of the month are skipped rather
+			// than rolled over into the next month.
+			if t.Day() != first.Day() {
+				continue
+			}
+		default:
+			return occurrences
+		}
+
+		if s.Until != nil && t.After(*s.Until) {
+			break
+		}
+		if s.Count > 0 && generated >= s.Count {
+			break
+		}
+		generated++
+		add(t)
+	}
+
+	return occurrences
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Canonical event statuses. Events move forward only:
+// draft -> scheduled -> ongoing -> finished, with scheduled -> finished
+// allowed for events that are closed before they start. Drafts are hidden
+// from buyers until published. Any event that is not over can be
+// cancelled, which is final like finished.
+const (
+	EventStatusDraft     = "draft"
+	EventStatusScheduled = "scheduled"
+	EventStatusOngoing   = "ongoing"
+	EventStatusFinished  = "finished"
+	EventStatusCancelled = "cancelled"
+)
+
+// SystemActor is recorded as the actor of transitions made by the scheduler.
+const SystemActor = "system"
+
+var ErrInvalidTransition = errors.New("invalid event status transition")
+
+var eventTransitions = map[string][]string{
+	EventStatusDraft:     {EventStatusScheduled, EventStatusCancelled},
+	EventStatusScheduled: {EventStatusOngoing, EventStatusFinished, EventStatusCancelled},
+	EventStatusOngoing:   {EventStatusFinished, EventStatusCancelled},
+}
+
+type EventStatusTransition struct {
+	ID         uint      `gorm:"primaryKey"`
+	EventID    uint      `gorm:"not null;index"`
+	FromStatus string    `gorm:"size:20;not null"`
+	ToStatus   string    `gorm:"size:20;not null"`
+	Actor      string    `gorm:"size:100;not null"` // admin email, or SystemActor
+	Reason     string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// CanTransition reports whether an event may move from one status to another.
+func CanTransition(from, to string) bool {
+	for _, allowed := range eventTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Published limits an event query to events visible to buyers.
+func Published(db *gorm.DB) *gorm.DB {
+	return db.Where("events.status <> ?", EventStatusDraft)
+}
+
+// Publish makes a draft visible to buyers.
+func (e *Event) Publish(tx *gorm.DB, actor string) error {
+	if err := e.TransitionTo(tx, EventStatusScheduled, actor); err != nil {
+		return err
+	}
+	now := time.Now()
+	e.PublishAt = nil
+	e.PublishedAt = &now
+	return tx.Model(e).Updates(map[string]interface{}{"publish_at": nil, "published_at": now}).Error
+}
+
+// Closed reports whether the event is finished or cancelled, after which
+// nothing about it can change.
+func (e Event) Closed() bool {
+	return e.Status == EventStatusFinished || e.Status == EventStatusCancelled
+}
+
+// TransitionTo moves the event to the given status and records who did it.
+func (e *Event) TransitionTo(tx *gorm.DB, to, actor string) error {
+	return e.TransitionWithReason(tx, to, actor, "")
+}
+
+// TransitionWithReason is TransitionTo with an explanation kept in the
+// event's status history.
+func (e *Event) TransitionWithReason(tx *gorm.DB, to, actor, reason string) error {
+	if !CanTransition(e.Status, to) {
+		return ErrInvalidTransition
+	}
+
+	transition := EventStatusTransition{
+		EventID:    e.ID,
+		FromStatus: e.Status,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     reason,
+	}
+	if err := tx.Model(e).Updates(map[string]interface{}{
+		"status":           to,
+		"schedule_version": gorm.Expr("schedule_version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+	e.Status = to
+	e.ScheduleVersion++
+	return tx.Create(&transition).Error
+}
+
+// PublishScheduledEvents publishes drafts whose publish_at has passed.
+func PublishScheduledEvents(db *gorm.DB) {
+	var events []Event
+	if err := db.Where("status = ? AND publish_at <= ?", EventStatusDraft, time.Now()).Find(&events).Error; err != nil {
+		log.Println("Failed to load events to publish:", err)
+		return
+	}
+
+	for _, event := range events {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, event.ID).Error; err != nil {
+				return err
+			}
+			// The publication may have been cancelled or done by hand since.
+			if event.Status != EventStatusDraft || event.PublishAt == nil || event.PublishAt.After(time.Now()) {
+				return nil
+			}
+			return event.Publish(tx, SystemActor)
+		})
+		if err != nil {
+			log.Println("Failed to publish event", event.ID, ":", err)
+		}
+	}
+}
+
+// AdvanceEventLifecycles starts events whose start time has passed and
+// finishes events whose end time has passed.
+func AdvanceEventLifecycles(db *gorm.DB) {
+	now := time.Now()
+
+	var events []Event
+	if err := db.Where("status IN ? AND starts_at <= ?", []string{EventStatusScheduled, EventStatusOngoing}, now).Find(&events).Error; err != nil {
+		log.Println("Failed to load events for lifecycle update:", err)
+		return
+	}
+
+	for _, event := range events {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Re-read under lock so a concurrent admin change is not overwritten.
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, event.ID).Error; err != nil {
+				return err
+			}
+			if event.Status == EventStatusScheduled && event.HasStarted(now) {
+				if err := event.TransitionTo(tx, EventStatusOngoing, SystemActor); err != nil {
+					return err
+				}
+			}
+			if event.Status == EventStatusOngoing && event.HasEnded(now) {
+				return event.TransitionTo(tx, EventStatusFinished, SystemActor)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Println("Failed to update lifecycle of event", event.ID, ":", err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification is a message queued for a user. Handlers only create them;
+// SendPendingNotifications delivers them in the background.
+type Notification struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	Email     string     `gorm:"size:100;not null"`
+	EventID   *uint      `gorm:"index"` // set for notifications about an event
+	Subject   string     `gorm:"size:200;not null"`
+	Body      string     `gorm:"type:text;not null"`
+	Status    string     `gorm:"size:20;not null;index"` // e.g., "pending", "sent", "failed"
+	Attempts  int        `gorm:"not null;default:0"`
+	SentAt    *time.Time // set once delivered
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
+}
+
+// maxNotificationAttempts is how often delivery is tried before a
+// notification is marked failed.
+const maxNotificationAttempts = 5
+
+// DeliverNotification sends a single notification. It only logs by default;
+// deployments with a mail provider replace it at startup.
+var DeliverNotification = func(n Notification) error {
+	log.Printf("Notification to %s: %s", n.Email, n.Subject)
+	return nil
+}
+
+// SendPendingNotifications delivers queued notifications, oldest first.
+func SendPendingNotifications(db *gorm.DB) {
+	var notifications []Notification
+	if err := db.Where("status = ?", "pending").Order("id").Limit(100).Find(&notifications).Error; err != nil {
+		log.Println("Failed to load pending notifications:", err)
+		return
+	}
+
+	for _, notification := range notifications {
+		updates := map[string]interface{}{"attempts": notification.Attempts + 1}
+		if err := DeliverNotification(notification); err != nil {
+			log.Println("Failed to deliver notification", notification.ID, ":", err)
+			if notification.Attempts+1 >= maxNotificationAttempts {
+				updates["status"] = "failed"
+			}
+		} else {
+			updates["status"] = "sent"
+			updates["sent_at"] = time.Now()
+		}
+		if err := db.Model(&notification).Updates(updates).Error; err != nil {
+			log.Println("Failed to update notification", notification.ID, ":", err)
+		}
+	}
+}
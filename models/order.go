@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Order struct {
+	ID        uint           `gorm:"primaryKey"`
+	UserID    uint           `gorm:"not null;index"`
+	User      User           `gorm:"foreignKey:UserID"`
+	EventID   uint           `gorm:"not null;index"`
+	Event     Event          `gorm:"foreignKey:EventID"`
+	Status    string         `gorm:"size:20;not null;index"` // e.g., "pending", "completed", "expired", "cancelled"
+	Total     float64        `gorm:"not null;check:total >= 0"`
+	ExpiresAt *time.Time     // payment deadline while the order is "pending"
+	Tickets   []Ticket       `gorm:"foreignKey:OrderID"`
+	CreatedAt time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
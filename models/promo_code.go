@@ -0,0 +1,35 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PromoCode struct {
+	ID           uint           `gorm:"primaryKey"`
+	Code         string         `gorm:"size:50;not null;unique"`
+	DiscountType string         `gorm:"size:20;not null"` // "percentage" or "fixed"
+	Value        float64        `gorm:"not null;check:value >= 0"`
+	MaxUses      int64          `gorm:"not null;default:0"` // 0 means unlimited
+	PerUserLimit int64          `gorm:"not null;default:0"` // 0 means unlimited
+	ValidFrom    *time.Time     // nil means valid immediately
+	ValidUntil   *time.Time     // nil means never expires
+	EventID      *uint          `gorm:"index"` // nil means valid for every event
+	Active       bool           `gorm:"not null"`
+	CreatedAt    time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+// DiscountFor returns the amount taken off a single ticket of the given price.
+// The discount never exceeds the price itself.
+func (p PromoCode) DiscountFor(price float64) float64 {
+	discount := p.Value
+	if p.DiscountType == "percentage" {
+		discount = price * p.Value / 100
+	}
+	discount = math.Round(math.Min(discount, price)*100) / 100
+	return discount
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Refund struct {
+	ID          uint           `gorm:"primaryKey"`
+	TicketID    uint           `gorm:"not null;index"`
+	Ticket      Ticket         `gorm:"foreignKey:TicketID"`
+	UserID      uint           `gorm:"not null;index"`
+	EventID     uint           `gorm:"not null;index"`
+	Amount      float64        `gorm:"not null;check:amount >= 0"`
+	Reason      string         `gorm:"type:text"`
+	Status      string         `gorm:"size:20;not null;index"` // e.g., "requested", "approved", "processed", "rejected"
+	ReviewedBy  string         `gorm:"size:100"`               // email of the admin who approved or rejected it
+	ApprovedAt  *time.Time     // set when an admin approves the refund
+	ProcessedAt *time.Time     // set once the money has been paid back
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
@@ -1,10 +1,34 @@
 package models
 
 type Report struct {
-	TotalTicketsSold int     `json:"total_tickets_sold"`
-	TotalRevenue     float64 `json:"total_revenue"`
-	EventID          uint    `json:"event_id"`
-	EventTitle       string  `json:"event_title"`
-	TicketsSold      int64   `json:"tickets_sold"`
-	RevenueGenerated float64 `json:"revenue_generated"`
+	TotalTicketsSold int               `json:"total_tickets_sold"`
+	TotalRevenue     float64           `json:"total_revenue"`
+	EventID          uint              `json:"event_id"`
+	EventTitle       string            `json:"event_title"`
+	TicketsSold      int64             `json:"tickets_sold"`
+	RevenueGenerated float64           `json:"revenue_generated"`
+	TotalDiscount    float64           `json:"total_discount"`
+	GrossRevenue     float64           `json:"gross_revenue"`
+	RefundedAmount   float64           `json:"refunded_amount"`
+	PendingRefunds   float64           `json:"pending_refunds"`
+	NetRevenue       float64           `json:"net_revenue"`
+	Tiers            []TierReport      `json:"tiers"`
+	PromoCodes       []PromoCodeReport `json:"promo_codes"`
+}
+
+type TierReport struct {
+	EventID      uint    `json:"event_id"`
+	TicketTypeID *uint   `json:"ticket_type_id"`
+	Name         string  `json:"name"`
+	TicketsSold  int64   `json:"tickets_sold"`
+	Revenue      float64 `json:"revenue"`
+	Discount     float64 `json:"discount"`
+}
+
+type PromoCodeReport struct {
+	PromoCodeID uint    `json:"promo_code_id"`
+	Code        string  `json:"code"`
+	Uses        int64   `json:"uses"`
+	Discount    float64 `json:"discount"`
+	Revenue     float64 `json:"revenue"`
 }
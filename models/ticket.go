@@ -1,20 +1,54 @@
 package models
 
 import (
+	"log"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Ticket struct {
-	ID        uint           `gorm:"primaryKey"`
-	UserID    uint           `gorm:"not null"`
-	User      User           `gorm:"foreignKey:UserID"`
-	EventID   uint           `gorm:"not null"`
-	Event     Event          `gorm:"foreignKey:EventID"`
-	Status    string         `gorm:"size:20;not null"` // e.g., "purchased", "cancelled"
-	Price     float64        `gorm:"not null;check:price >= 0"`
-	CreatedAt time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID           uint           `gorm:"primaryKey"`
+	UserID       uint           `gorm:"not null"`
+	User         User           `gorm:"foreignKey:UserID"`
+	EventID      uint           `gorm:"not null"`
+	Event        Event          `gorm:"foreignKey:EventID"`
+	TicketTypeID *uint          `gorm:"index"`
+	TicketType   *TicketType    `gorm:"foreignKey:TicketTypeID"`
+	OrderID      *uint          `gorm:"index"`
+	SeatID       *uint          `gorm:"index"`
+	Seat         *Seat          `gorm:"foreignKey:SeatID"`
+	Status       string         `gorm:"size:20;not null;index"`    // e.g., "reserved", "purchased", "cancelled", "expired"
+	Price        float64        `gorm:"not null;check:price >= 0"` // amount paid, after any discount
+	PromoCodeID  *uint          `gorm:"index"`
+	Discount     float64        `gorm:"not null;default:0"`
+	ExpiresAt    *time.Time     `gorm:"index"` // set while the ticket is "reserved"
+	PaidAt       *time.Time     // set once the ticket has been paid for
+	CodeVersion  int            `gorm:"not null;default:0"` // bumped whenever ownership changes to void earlier ticket codes
+	Code         string         `gorm:"-"`                  // signed admission code, only filled in for the ticket holder
+	CreatedAt    time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+// HoldsInventory limits a ticket query to tickets that count against capacity:
+// purchased tickets and reservations that have not expired yet.
+func HoldsInventory(db *gorm.DB) *gorm.DB {
+	return db.Where("(tickets.status = ? OR (tickets.status = ? AND tickets.expires_at > ?))", "purchased", "reserved", time.Now())
+}
+
+// ReleaseExpiredHolds marks lapsed reservations and their pending orders as
+// expired so the seats return to inventory.
+func ReleaseExpiredHolds(db *gorm.DB) {
+	now := time.Now()
+	if err := db.Model(&Ticket{}).
+		Where("status = ? AND expires_at <= ?", "reserved", now).
+		Updates(map[string]interface{}{"status": "expired", "expires_at": nil}).Error; err != nil {
+		log.Println("Failed to release expired holds:", err)
+	}
+	if err := db.Model(&Order{}).
+		Where("status = ? AND expires_at <= ?", "pending", now).
+		Update("status", "expired").Error; err != nil {
+		log.Println("Failed to expire pending orders:", err)
+	}
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// TransferOfferDuration is how long a recipient has to accept a transfer.
+const TransferOfferDuration = 7 * 24 * time.Hour
+
+type TicketTransfer struct {
+	ID          uint       `gorm:"primaryKey"`
+	TicketID    uint       `gorm:"not null;index"`
+	Ticket      Ticket     `gorm:"foreignKey:TicketID"`
+	FromUserID  uint       `gorm:"not null;index"`
+	ToEmail     string     `gorm:"size:100;not null;index"`
+	ToUserID    *uint      `gorm:"index"`                  // set once the recipient accepts
+	Status      string     `gorm:"size:20;not null;index"` // e.g., "pending", "accepted", "declined", "cancelled"
+	ExpiresAt   time.Time  `gorm:"not null"`
+	RespondedAt *time.Time // when the offer was accepted, declined or cancelled
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
+}
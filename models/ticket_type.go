@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TicketType struct {
+	ID         uint           `gorm:"primaryKey"`
+	EventID    uint           `gorm:"not null;index"`
+	Name       string         `gorm:"size:100;not null"` // e.g., "VIP", "General Admission", "Early Bird"
+	Price      float64        `gorm:"not null;check:price >= 0"`
+	Capacity   int64          `gorm:"not null;check:capacity >= 0"`
+	MaxPerUser int64          `gorm:"not null;default:0;check:max_per_user >= 0"` // 0 means no limit
+	SalesStart *time.Time     // nil means on sale as soon as the event is
+	SalesEnd   *time.Time     // nil means on sale until the event starts
+	CreatedAt  time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
@@ -11,7 +11,7 @@ type User struct {
 	Name      string         `gorm:"size:100;not null"`
 	Email     string         `gorm:"size:100;unique;not null"`
 	Password  string         `gorm:"not null"`
-	Role      string         `gorm:"size:20;not null"` // e.g., "user" or "admin"
+	Role      string         `gorm:"size:20;not null"` // e.g., "user", "scanner" or "admin"
 	CreatedAt time.Time      `gorm:"autoCreateTime"`
 	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
 	DeletedAt gorm.DeletedAt `gorm:"index"`
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type Venue struct {
+	ID        uint           `gorm:"primaryKey"`
+	Name      string         `gorm:"size:200;not null;unique"`
+	Address   string         `gorm:"size:255"`
+	Sections  []VenueSection `gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
+}
+
+type VenueSection struct {
+	ID      uint   `gorm:"primaryKey"`
+	VenueID uint   `gorm:"not null;index"`
+	Name    string `gorm:"size:100;not null"` // e.g., "Orchestra", "Balcony"
+	Seats   []Seat `gorm:"foreignKey:SectionID;constraint:OnDelete:CASCADE"`
+}
+
+type Seat struct {
+	ID        uint   `gorm:"primaryKey"`
+	SectionID uint   `gorm:"not null;uniqueIndex:idx_seat_position"`
+	Row       string `gorm:"size:10;not null;uniqueIndex:idx_seat_position"`
+	Number    int    `gorm:"not null;uniqueIndex:idx_seat_position"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OfferDuration is how long a waitlisted user has to buy the seat they were
+// offered before it passes to the next person in line.
+const OfferDuration = time.Hour
+
+type WaitlistEntry struct {
+	ID             uint       `gorm:"primaryKey"`
+	EventID        uint       `gorm:"not null;index"`
+	Event          Event      `gorm:"foreignKey:EventID"`
+	UserID         uint       `gorm:"not null;index"`
+	User           User       `gorm:"foreignKey:UserID"`
+	Status         string     `gorm:"size:20;not null;index"` // e.g., "waiting", "offered", "fulfilled", "expired", "left", "cancelled"
+	OfferExpiresAt *time.Time // set while the entry is "offered"
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime"`
+}
+
+// ActiveOffers limits a waitlist query to offers that are still redeemable.
+func ActiveOffers(db *gorm.DB) *gorm.DB {
+	return db.Where("waitlist_entries.status = ? AND waitlist_entries.offer_expires_at > ?", "offered", time.Now())
+}
+
+// OfferWaitlistSeats hands any unclaimed capacity of the event to the next
+// users in its waitlist, oldest entry first.
+func OfferWaitlistSeats(db *gorm.DB, eventID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var event Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, eventID).Error; err != nil {
+			return err
+		}
+		if event.Closed() || event.HasStarted(time.Now()) {
+			return nil
+		}
+
+		var ticketsSold, offersHeld int64
+		if err := tx.Model(&Ticket{}).Scopes(HoldsInventory).Where("event_id = ?", event.ID).Count(&ticketsSold).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&WaitlistEntry{}).Scopes(ActiveOffers).Where("event_id = ?", event.ID).Count(&offersHeld).Error; err != nil {
+			return err
+		}
+
+		free := event.Capacity - ticketsSold - offersHeld
+		if free <= 0 {
+			return nil
+		}
+
+		var entries []WaitlistEntry
+		if err := tx.Where("event_id = ? AND status = ?", event.ID, "waiting").Order("id").Limit(int(free)).Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(entries))
+		for i, entry := range entries {
+			ids[i] = entry.ID
+		}
+		return tx.Model(&WaitlistEntry{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":           "offered",
+			"offer_expires_at": time.Now().Add(OfferDuration),
+		}).Error
+	})
+}
+
+// ProcessWaitlists expires lapsed offers and offers any freed capacity to the
+// next waitlisted users of every event that still has people waiting.
+func ProcessWaitlists(db *gorm.DB) {
+	if err := db.Model(&WaitlistEntry{}).
+		Where("status = ? AND offer_expires_at <= ?", "offered", time.Now()).
+		Update("status", "expired").Error; err != nil {
+		log.Println("Failed to expire waitlist offers:", err)
+	}
+
+	var eventIDs []uint
+	db.Model(&WaitlistEntry{}).Where("status = ?", "waiting").Distinct().Pluck("event_id", &eventIDs)
+	for _, eventID := range eventIDs {
+		if err := OfferWaitlistSeats(db, eventID); err != nil {
+			log.Println("Failed to process waitlist for event", eventID, ":", err)
+		}
+	}
+}
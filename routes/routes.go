@@ -1,43 +1,122 @@
 package routes
 
 import (
+	"ticketink/config"
 	"ticketink/handlers"
 	"ticketink/middleware"
+	"ticketink/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
+	media := storage.NewLocal(config.MediaDir(), config.MediaBaseURL())
+	r.Static("/media", config.MediaDir())
 
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "pong"})
 	})
 
+	r.GET("/events", handlers.ListPublicEvents(db, media))
+	r.GET("/events/:id", handlers.GetPublicEvent(db, media))
+	r.GET("/events.ics", handlers.GetEventsCalendar(db))
+	r.GET("/calendar/:token", handlers.GetUserCalendar(db))
+
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS())
+
 	r.POST("/login", handlers.Login(db))
 	r.POST("/register", handlers.Register(db))
 
 	api := r.Group("/api")
 	api.Use(middleware.AuthMiddleware(db))
 	{
-		api.GET("/events", handlers.ListEvents(db))
+		api.GET("/events", handlers.ListEvents(db, media))
+		api.GET("/events/:id", handlers.GetEvent(db, media))
+		api.GET("/events/:id/media", handlers.ListEventMedia(db, media))
+		api.GET("/categories", handlers.ListCategories(db))
+		api.GET("/events/:id/ticket-types", handlers.ListTicketTypes(db))
+		api.GET("/events/:id/seats", handlers.GetEventSeats(db))
+		api.GET("/events/:id/waitlist", handlers.GetWaitlistStatus(db))
+		api.POST("/events/:id/waitlist", handlers.JoinWaitlist(db))
+		api.DELETE("/events/:id/waitlist", handlers.LeaveWaitlist(db))
 
 		api.GET("/tickets", handlers.GetTickets(db))
 		api.POST("/tickets", handlers.PurchaseTicket(db))
 		api.GET("/tickets/:id", handlers.GetTicketByID(db))
+		api.GET("/tickets/:id/qr", handlers.GetTicketQRCode(db))
 		api.PATCH("/tickets/:id", handlers.UpdateTicket(db))
+		api.POST("/tickets/:id/transfer", handlers.TransferTicket(db))
+		api.GET("/tickets/:id/transfers", handlers.GetTicketTransferHistory(db))
+
+		api.GET("/transfers", handlers.GetTransfers(db))
+		api.POST("/transfers/:id/accept", handlers.AcceptTransfer(db))
+		api.POST("/transfers/:id/decline", handlers.DeclineTransfer(db))
+		api.POST("/transfers/:id/cancel", handlers.CancelTransfer(db))
+
+		api.GET("/orders", handlers.GetOrders(db))
+		api.POST("/orders", handlers.CreateOrder(db))
+		api.GET("/orders/:id", handlers.GetOrderByID(db))
+		api.POST("/orders/:id/confirm", handlers.ConfirmOrder(db))
+
+		api.GET("/refunds", handlers.GetMyRefunds(db))
+
+		api.GET("/calendar", handlers.GetCalendarFeed(db))
+		api.POST("/calendar/reset", handlers.ResetCalendarFeed(db))
 
 		api.POST("/logout", handlers.Logout(db))
 	}
 
+	scanner := api.Group("")
+	scanner.Use(middleware.ScannerOnly())
+	{
+		scanner.POST("/checkin", handlers.CheckInTicket(db))
+	}
+
 	admin := api.Group("/admin")
 	admin.Use(middleware.AdminOnly())
 	{
 		admin.GET("/reports/summary", handlers.GetRevenueSummary(db))
 		admin.GET("/reports/event/:id", handlers.GetEventReport(db))
 		admin.POST("/events", handlers.CreateEvent(db))
+		admin.GET("/events/export", handlers.ExportEvents(db))
+		admin.POST("/events/import", handlers.ImportEvents(db))
 		admin.PUT("/events/:id", handlers.UpdateEvent(db))
 		admin.PATCH("/events/:id", handlers.UpdateEventStatus(db))
+		admin.GET("/events/:id/status-history", handlers.GetEventStatusHistory(db))
+		admin.POST("/events/:id/publish", handlers.PublishEvent(db))
+		admin.POST("/events/:id/cancel", handlers.CancelEvent(db))
 		admin.DELETE("/events/:id", handlers.DeleteEvent(db))
+		admin.POST("/events/:id/media", handlers.UploadEventMedia(db, media))
+		admin.DELETE("/events/:id/media/:mediaId", handlers.DeleteEventMedia(db, media))
+
+		admin.POST("/events/:id/ticket-types", handlers.CreateTicketType(db))
+		admin.PUT("/ticket-types/:id", handlers.UpdateTicketType(db))
+		admin.DELETE("/ticket-types/:id", handlers.DeleteTicketType(db))
+
+		admin.GET("/promo-codes", handlers.ListPromoCodes(db))
+		admin.POST("/promo-codes", handlers.CreatePromoCode(db))
+		admin.PUT("/promo-codes/:id", handlers.UpdatePromoCode(db))
+		admin.DELETE("/promo-codes/:id", handlers.DeletePromoCode(db))
+
+		admin.GET("/refunds", handlers.ListRefunds(db))
+		admin.POST("/refunds/:id/approve", handlers.ApproveRefund(db))
+		admin.POST("/refunds/:id/reject", handlers.RejectRefund(db))
+		admin.POST("/refunds/:id/process", handlers.ProcessRefund(db))
+
+		admin.GET("/events/:id/attendance", handlers.GetEventAttendance(db))
+
+		admin.POST("/categories", handlers.CreateCategory(db))
+		admin.PUT("/categories/:id", handlers.UpdateCategory(db))
+		admin.DELETE("/categories/:id", handlers.DeleteCategory(db))
+
+		admin.GET("/venues", handlers.ListVenues(db))
+		admin.POST("/venues", handlers.CreateVenue(db))
+		admin.GET("/venues/:id", handlers.GetVenue(db))
+
+		admin.POST("/series", handlers.CreateSeries(db))
+		admin.GET("/series/:id", handlers.GetSeries(db))
+		admin.PUT("/series/:id", handlers.UpdateSeries(db))
+		admin.PATCH("/users/:id/role", handlers.UpdateUserRole(db))
 	}
 }
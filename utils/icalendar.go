@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// CalendarEvent is a single VEVENT of an iCalendar feed.
+type CalendarEvent struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	URL          string
+	Start        time.Time
+	End          time.Time
+	LastModified time.Time
+	Sequence     int
+	Cancelled    bool
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+const icalTimeFormat = "20060102T150405Z"
+
+// WriteCalendar writes the events as an RFC 5545 calendar. Times are
+// written in UTC so no VTIMEZONE definitions are needed.
+func WriteCalendar(w io.Writer, name string, events []CalendarEvent) error {
+	var b strings.Builder
+	line := func(property, value string) {
+		writeICalLine(&b, property+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//ticketink//Events//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", icalEscaper.Replace(name))
+
+	stamp := time.Now().UTC().Format(icalTimeFormat)
+	for _, event := range events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", stamp)
+		line("DTSTART", event.Start.UTC().Format(icalTimeFormat))
+		line("DTEND", event.End.UTC().Format(icalTimeFormat))
+		line("LAST-MODIFIED", event.LastModified.UTC().Format(icalTimeFormat))
+		line("SEQUENCE", fmt.Sprint(event.Sequence))
+		line("SUMMARY", icalEscaper.Replace(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", icalEscaper.Replace(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION", icalEscaper.Replace(event.Location))
+		}
+		if event.URL != "" {
+			line("URL", event.URL)
+		}
+		if event.Cancelled {
+			line("STATUS", "CANCELLED")
+		} else {
+			line("STATUS", "CONFIRMED")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeICalLine folds content lines longer than 75 octets as RFC 5545
+// requires, without splitting UTF-8 sequences.
+func writeICalLine(b *strings.Builder, content string) {
+	const limit = 75
+	width := 0
+	for _, r := range content {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+}
@@ -1,14 +1,21 @@
 package utils
 
 import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"ticketink/config"
 	"ticketink/models"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var jwtSecret = []byte("your-secret-key")
-
 type Claims struct {
 	Email string `json:"email"`
 	Name  string `json:"name"`
@@ -16,7 +23,145 @@ type Claims struct {
 	*jwt.RegisteredClaims
 }
 
+// jwtKey is a loaded key of the configured key set. signKey is nil for keys
+// that are only accepted for verification.
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+type jwtKeyring struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey
+	ordered []*jwtKey
+}
+
+var (
+	keyringOnce sync.Once
+	keyring     *jwtKeyring
+	keyringErr  error
+)
+
+// LoadJWTKeys loads the configured signing and verification keys. It is
+// called at startup so a broken key configuration stops the server instead
+// of failing every login.
+func LoadJWTKeys() error {
+	keyringOnce.Do(func() {
+		var set config.JWTKeySet
+		if set, keyringErr = config.JWTKeys(); keyringErr == nil {
+			keyring, keyringErr = newJWTKeyring(set)
+		}
+	})
+	return keyringErr
+}
+
+func newJWTKeyring(set config.JWTKeySet) (*jwtKeyring, error) {
+	ring := &jwtKeyring{keys: map[string]*jwtKey{}}
+	for _, cfg := range set.Keys {
+		if cfg.ID == "" {
+			return nil, errors.New("every JWT key needs a kid")
+		}
+		if _, ok := ring.keys[cfg.ID]; ok {
+			return nil, fmt.Errorf("duplicate JWT kid %q", cfg.ID)
+		}
+		key, err := loadJWTKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %q: %w", cfg.ID, err)
+		}
+		ring.keys[key.id] = key
+		ring.ordered = append(ring.ordered, key)
+	}
+
+	ring.signing = ring.keys[set.SigningKey]
+	if ring.signing == nil {
+		return nil, fmt.Errorf("signing key %q is not in the JWT key set", set.SigningKey)
+	}
+	if ring.signing.signKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", set.SigningKey)
+	}
+	return ring, nil
+}
+
+func readPEM(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+func loadJWTKey(cfg config.JWTKey) (*jwtKey, error) {
+	key := &jwtKey{id: cfg.ID}
+
+	privatePEM, err := readPEM(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	publicPEM, err := readPEM(cfg.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Algorithm {
+	case "HS256":
+		if len(cfg.Secret) < 32 {
+			return nil, errors.New("HS256 secrets must be at least 32 bytes")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(cfg.Secret)
+		key.verifyKey = []byte(cfg.Secret)
+
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if privatePEM != nil {
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey, key.verifyKey = private, &private.PublicKey
+		} else if publicPEM != nil {
+			if key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, err
+			}
+		}
+
+	case "EdDSA":
+		key.method = jwt.SigningMethodEdDSA
+		if privatePEM != nil {
+			private, err := jwt.ParseEdPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey, key.verifyKey = private, private.(ed25519.PrivateKey).Public()
+		} else if publicPEM != nil {
+			if key.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use HS256, RS256 or EdDSA", cfg.Algorithm)
+	}
+
+	if key.verifyKey == nil {
+		return nil, errors.New("private_key_file or public_key_file is required")
+	}
+	return key, nil
+}
+
+func loadedKeyring() (*jwtKeyring, error) {
+	if err := LoadJWTKeys(); err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
 func GenerateToken(user models.User) (string, error) {
+	ring, err := loadedKeyring()
+	if err != nil {
+		return "", err
+	}
 
 	claims := Claims{
 		Email: user.Email,
@@ -29,33 +174,100 @@ func GenerateToken(user models.User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	token := jwt.NewWithClaims(ring.signing.method, claims)
+	token.Header["kid"] = ring.signing.id
+	return token.SignedString(ring.signing.signKey)
 }
 
+// ValidateToken verifies a token against the key named by its kid header,
+// so tokens signed with a key that is being rotated out stay valid while
+// the key is still in the key set. Tokens without a kid are checked against
+// every key of the same algorithm.
 func ValidateToken(tokenString string) (*Claims, error) {
-	claims := &Claims{}
-
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+	ring, err := loadedKeyring()
+	if err != nil {
+		return nil, err
+	}
 
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
+	var candidates []*jwtKey
+	if unverified, _, err := new(jwt.Parser).ParseUnverified(tokenString, &Claims{}); err == nil {
+		if kid, ok := unverified.Header["kid"].(string); ok {
+			if key := ring.keys[kid]; key != nil {
+				candidates = []*jwtKey{key}
+			}
+		} else {
+			candidates = ring.ordered
 		}
-		return jwtSecret, nil
-	})
+	}
 
-	if err != nil {
+	for _, key := range candidates {
+		claims := &Claims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			// Only the algorithm the key was configured with is accepted,
+			// so an RSA public key can never be used as an HMAC secret.
+			if token.Method.Alg() != key.method.Alg() {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return key.verifyKey, nil
+		})
 
-		if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors&jwt.ValidationErrorExpired != 0 {
-			return nil, jwt.ErrTokenExpired
+		if err != nil {
+			if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors&jwt.ValidationErrorExpired != 0 {
+				return nil, jwt.ErrTokenExpired
+			}
+			continue
 		}
 
-		return nil, jwt.NewValidationError("Invalid token", jwt.ValidationErrorClaimsInvalid)
+		if token.Valid {
+			return claims, nil
+		}
 	}
 
-	if !token.Valid {
-		return nil, jwt.NewValidationError("Invalid token", jwt.ValidationErrorClaimsInvalid)
+	return nil, jwt.NewValidationError("Invalid token", jwt.ValidationErrorClaimsInvalid)
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS returns the public keys of the key set for other services to verify
+// tokens with. HS256 secrets are never published.
+func JWKS() ([]JWK, error) {
+	ring, err := loadedKeyring()
+	if err != nil {
+		return nil, err
 	}
 
-	return claims, nil
+	keys := []JWK{}
+	for _, key := range ring.ordered {
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.id,
+				Use:       "sig",
+				Algorithm: key.method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.id,
+				Use:       "sig",
+				Algorithm: key.method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return keys, nil
 }
@@ -0,0 +1,313 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ticketink/config"
+	"ticketink/models"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testSecret    = "0123456789abcdef0123456789abcdef"
+	testOldSecret = "fedcba9876543210fedcba9876543210"
+)
+
+// writePEM stores der as a PEM block in a file under dir and returns its path.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type testKeys struct {
+	rsaKey     *rsa.PrivateKey
+	rsaPrivate string // PEM file of rsaKey
+	rsaPublic  string // PEM file of the public half of rsaKey
+	edKey      ed25519.PrivateKey
+	edPrivate  string
+	edPublic   string
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	dir := t.TempDir()
+	keys := testKeys{}
+
+	var err error
+	if keys.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	keys.rsaPrivate = writePEM(t, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(keys.rsaKey))
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&keys.rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.rsaPublic = writePEM(t, dir, "rsa.pub.pem", "PUBLIC KEY", rsaPublic)
+
+	edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.edKey = edKey
+	edPrivate, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.edPrivate = writePEM(t, dir, "ed.pem", "PRIVATE KEY", edPrivate)
+	edPublic, err := x509.MarshalPKIXPublicKey(edPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.edPublic = writePEM(t, dir, "ed.pub.pem", "PUBLIC KEY", edPublic)
+	return keys
+}
+
+// useKeyring makes set the key set used by GenerateToken and ValidateToken
+// for the rest of the test.
+func useKeyring(t *testing.T, set config.JWTKeySet) {
+	t.Helper()
+	ring, err := newJWTKeyring(set)
+	if err != nil {
+		t.Fatal("loading key set:", err)
+	}
+	keyringOnce.Do(func() {})
+	previous, previousErr := keyring, keyringErr
+	keyring, keyringErr = ring, nil
+	t.Cleanup(func() { keyring, keyringErr = previous, previousErr })
+}
+
+func TestNewJWTKeyring(t *testing.T) {
+	keys := newTestKeys(t)
+
+	tests := []struct {
+		name    string
+		set     config.JWTKeySet
+		wantErr string
+	}{
+		{
+			name: "HS256 signing key",
+			set: config.JWTKeySet{SigningKey: "a", Keys: []config.JWTKey{
+				{ID: "a", Algorithm: "HS256", Secret: testSecret},
+			}},
+		},
+		{
+			name: "RS256 signing key with verification only keys",
+			set: config.JWTKeySet{SigningKey: "new", Keys: []config.JWTKey{
+				{ID: "new", Algorithm: "RS256", PrivateKeyFile: keys.rsaPrivate},
+				{ID: "old", Algorithm: "EdDSA", PublicKeyFile: keys.edPublic},
+				{ID: "older", Algorithm: "HS256", Secret: testOldSecret},
+			}},
+		},
+		{
+			name: "EdDSA signing key",
+			set: config.JWTKeySet{SigningKey: "ed", Keys: []config.JWTKey{
+				{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: keys.edPrivate},
+			}},
+		},
+		{
+			name: "missing kid",
+			set: config.JWTKeySet{SigningKey: "", Keys: []config.JWTKey{
+				{Algorithm: "HS256", Secret: testSecret},
+			}},
+			wantErr: "needs a kid",
+		},
+		{
+			name: "duplicate kid",
+			set: config.JWTKeySet{SigningKey: "a", Keys: []config.JWTKey{
+				{ID: "a", Algorithm: "HS256", Secret: testSecret},
+				{ID: "a", Algorithm: "HS256", Secret: testOldSecret},
+			}},
+			wantErr: "duplicate JWT kid",
+		},
+		{
+			name: "short HS256 secret",
+			set: config.JWTKeySet{SigningKey: "a", Keys: []config.JWTKey{
+				{ID: "a", Algorithm: "HS256", Secret: "too short"},
+			}},
+			wantErr: "at least 32 bytes",
+		},
+		{
+			name: "unsupported algorithm",
+			set: config.JWTKeySet{SigningKey: "a", Keys: []config.JWTKey{
+				{ID: "a", Algorithm: "none"},
+			}},
+			wantErr: "unsupported algorithm",
+		},
+		{
+			name: "RS256 key without key files",
+			set: config.JWTKeySet{SigningKey: "a", Keys: []config.JWTKey{
+				{ID: "a", Algorithm: "RS256"},
+			}},
+			wantErr: "private_key_file or public_key_file is required",
+		},
+		{
+			name: "signing key not in the set",
+			set: config.JWTKeySet{SigningKey: "b", Keys: []config.JWTKey{
+				{ID: "a", Algorithm: "HS256", Secret: testSecret},
+			}},
+			wantErr: "is not in the JWT key set",
+		},
+		{
+			name: "signing key without private key",
+			set: config.JWTKeySet{SigningKey: "a", Keys: []config.JWTKey{
+				{ID: "a", Algorithm: "RS256", PublicKeyFile: keys.rsaPublic},
+			}},
+			wantErr: "has no private key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newJWTKeyring(tt.set)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func testClaims(expiresAt time.Time) Claims {
+	return Claims{
+		Email: "buyer@example.com",
+		Name:  "Buyer",
+		Role:  "user",
+		RegisteredClaims: &jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+}
+
+// signToken signs claims with method and key, setting the kid header unless
+// kid is empty.
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal("signing token:", err)
+	}
+	return signed
+}
+
+func TestValidateToken(t *testing.T) {
+	keys := newTestKeys(t)
+	useKeyring(t, config.JWTKeySet{SigningKey: "current", Keys: []config.JWTKey{
+		{ID: "current", Algorithm: "RS256", PrivateKeyFile: keys.rsaPrivate},
+		{ID: "previous", Algorithm: "EdDSA", PublicKeyFile: keys.edPublic},
+		{ID: "legacy", Algorithm: "HS256", Secret: testOldSecret},
+	}})
+
+	current, err := GenerateToken(models.User{Email: "buyer@example.com", Name: "Buyer", Role: "user"})
+	if err != nil {
+		t.Fatal("generating token:", err)
+	}
+
+	valid := testClaims(time.Now().Add(time.Hour))
+	rsaPublicPEM, err := os.ReadFile(keys.rsaPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(current, ".")
+	tamperedClaims := testClaims(time.Now().Add(time.Hour))
+	tamperedClaims.Role = "admin"
+	tampered := strings.Split(signToken(t, jwt.SigningMethodRS256, "current", otherKey, tamperedClaims), ".")
+
+	tests := []struct {
+		name        string
+		token       string
+		wantErr     bool
+		wantExpired bool
+	}{
+		{name: "signed with the signing key", token: current},
+		{name: "signed with a key kept for verification", token: signToken(t, jwt.SigningMethodEdDSA, "previous", keys.edKey, valid)},
+		{name: "HS256 key kept for verification", token: signToken(t, jwt.SigningMethodHS256, "legacy", []byte(testOldSecret), valid)},
+		{name: "no kid is checked against every key", token: signToken(t, jwt.SigningMethodHS256, "", []byte(testOldSecret), valid)},
+		{name: "unknown kid", token: signToken(t, jwt.SigningMethodHS256, "retired", []byte(testOldSecret), valid), wantErr: true},
+		{name: "kid of a different key", token: signToken(t, jwt.SigningMethodHS256, "current", []byte(testOldSecret), valid), wantErr: true},
+		{name: "RSA public key used as HMAC secret", token: signToken(t, jwt.SigningMethodHS256, "current", rsaPublicPEM, valid), wantErr: true},
+		{name: "algorithm differs from the key's", token: signToken(t, jwt.SigningMethodRS256, "previous", keys.rsaKey, valid), wantErr: true},
+		{name: "unsigned token", token: signToken(t, jwt.SigningMethodNone, "current", jwt.UnsafeAllowNoneSignatureType, valid), wantErr: true},
+		{name: "signed with an unknown key", token: signToken(t, jwt.SigningMethodRS256, "current", otherKey, valid), wantErr: true},
+		{name: "tampered claims", token: parts[0] + "." + tampered[1] + "." + parts[2], wantErr: true},
+		{name: "expired", token: signToken(t, jwt.SigningMethodRS256, "current", keys.rsaKey, testClaims(time.Now().Add(-time.Minute))), wantErr: true, wantExpired: true},
+		{name: "not a token", token: "not.a.token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ValidateToken(tt.token)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if claims.Email != "buyer@example.com" || claims.Role != "user" {
+					t.Fatalf("unexpected claims %+v", claims)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("token was accepted with claims %+v", claims)
+			}
+			if expired := errors.Is(err, jwt.ErrTokenExpired); expired != tt.wantExpired {
+				t.Fatalf("got error %v, expired %v, want expired %v", err, expired, tt.wantExpired)
+			}
+		})
+	}
+}
+
+func TestJWKSPublishesOnlyPublicKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	useKeyring(t, config.JWTKeySet{SigningKey: "current", Keys: []config.JWTKey{
+		{ID: "current", Algorithm: "RS256", PrivateKeyFile: keys.rsaPrivate},
+		{ID: "previous", Algorithm: "EdDSA", PublicKeyFile: keys.edPublic},
+		{ID: "legacy", Algorithm: "HS256", Secret: testOldSecret},
+	}})
+
+	jwks, err := JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, key := range jwks {
+		got[key.KeyID] = key.Algorithm
+	}
+	want := map[string]string{"current": "RS256", "previous": "EdDSA"}
+	if len(got) != len(want) {
+		t.Fatalf("got keys %v, want %v", got, want)
+	}
+	for kid, alg := range want {
+		if got[kid] != alg {
+			t.Errorf("key %q has algorithm %q, want %q", kid, got[kid], alg)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"ticketink/models"
+)
+
+// useTicketCodeKey makes key the ticket code signing key for the rest of
+// the test.
+func useTicketCodeKey(t *testing.T, key string) {
+	t.Helper()
+	ticketCodeKeyOnce.Do(func() {})
+	previous, previousErr := ticketCodeKey, ticketCodeKeyErr
+	ticketCodeKey, ticketCodeKeyErr = []byte(key), nil
+	t.Cleanup(func() { ticketCodeKey, ticketCodeKeyErr = previous, previousErr })
+}
+
+// forgeTicketCode builds a code for payload signed with key, using prefix
+// in place of the current code version.
+func forgeTicketCode(prefix, payload, key string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(prefix + "." + encoded))
+	return prefix + "." + encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseTicketCode(t *testing.T) {
+	const key = "ticket-code-test-key"
+	useTicketCodeKey(t, key)
+
+	ticket := models.Ticket{ID: 12, EventID: 34, UserID: 56, CodeVersion: 2}
+	code, err := GenerateTicketCode(ticket)
+	if err != nil {
+		t.Fatal("generating ticket code:", err)
+	}
+	parts := strings.Split(code, ".")
+	otherPayload := base64.RawURLEncoding.EncodeToString([]byte("12:34:57:2"))
+
+	tests := []struct {
+		name string
+		code string
+		want TicketCodePayload
+		ok   bool
+	}{
+		{name: "generated code", code: code, want: TicketCodePayload{TicketID: 12, EventID: 34, UserID: 56, Version: 2}, ok: true},
+		{name: "surrounding whitespace", code: "  " + code + "\n", want: TicketCodePayload{TicketID: 12, EventID: 34, UserID: 56, Version: 2}, ok: true},
+		{name: "issued before a transfer", code: forgeTicketCode(ticketCodePrefix, "12:34:55:1", key), want: TicketCodePayload{TicketID: 12, EventID: 34, UserID: 55, Version: 1}, ok: true},
+		{name: "payload swapped for another holder", code: parts[0] + "." + otherPayload + "." + parts[2]},
+		{name: "tampered signature", code: parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2]))},
+		{name: "missing signature", code: parts[0] + "." + parts[1]},
+		{name: "signed with another key", code: forgeTicketCode(ticketCodePrefix, "12:34:56:2", "some-other-key")},
+		{name: "old code format", code: forgeTicketCode("TK0", "12:34:56:2", key)},
+		{name: "unknown prefix", code: "XX1." + parts[1] + "." + parts[2]},
+		{name: "malformed payload", code: forgeTicketCode(ticketCodePrefix, "12:34", key)},
+		{name: "trailing characters after the signature", code: forgeTicketCode(ticketCodePrefix, "12:34:56:2", key) + "!"},
+		{name: "empty", code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := ParseTicketCode(tt.code)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidTicketCode) {
+					t.Fatalf("got payload %+v and error %v, want ErrInvalidTicketCode", payload, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if payload != tt.want {
+				t.Fatalf("got payload %+v, want %+v", payload, tt.want)
+			}
+		})
+	}
+}
+
+func TestTicketCodeChangesWithCodeVersion(t *testing.T) {
+	useTicketCodeKey(t, "ticket-code-test-key")
+
+	before, err := GenerateTicketCode(models.Ticket{ID: 1, EventID: 2, UserID: 3, CodeVersion: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := GenerateTicketCode(models.Ticket{ID: 1, EventID: 2, UserID: 4, CodeVersion: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before == after {
+		t.Fatal("transferring a ticket did not change its code")
+	}
+
+	payload, err := ParseTicketCode(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Version != 0 || payload.UserID != 3 {
+		t.Fatalf("old code parsed as %+v, check-in could not tell it is outdated", payload)
+	}
+}